@@ -0,0 +1,275 @@
+// gocodec inspects files written by gocodec
+//
+//	gocodec list FILE
+//	gocodec dump [-schema GO_FILE] [-type TYPE] [-frame N] FILE
+//	gocodec tojson [-schema GO_FILE] -type TYPE [-frame N] FILE
+//	gocodec fromjson [-schema GO_FILE] -type TYPE [JSON_FILE]
+//	gocodec diff [-schema GO_FILE] -type TYPE [-frame N] FILE_A FILE_B
+//
+// TYPE is a go type expression, such as 'struct { Id int; Tags []string }'.
+// With -schema, TYPE can also use the types declared in the go file, such as a copy of the file
+// declaring the types written to the frames, so that 'Order' or '[]Order' is enough.
+// Without TYPE, the frame is dumped as hex.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/esdb/gocodec"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+)
+
+var errUsage = errors.New("usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if err == errUsage {
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	switch args[0] {
+	case "list":
+		return list(args[1:], stdout)
+	case "dump":
+		return dump(args[1:], stdout)
+	case "tojson":
+		return toJSON(args[1:], stdout)
+	case "fromjson":
+		return fromJSON(args[1:], stdin, stdout)
+	case "diff":
+		return diff(args[1:], stdout)
+	}
+	return errUsage
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "  gocodec list FILE")
+	fmt.Fprintln(os.Stderr, "  gocodec dump [-schema GO_FILE] [-type TYPE] [-frame N] FILE")
+	fmt.Fprintln(os.Stderr, "  gocodec tojson [-schema GO_FILE] -type TYPE [-frame N] FILE")
+	fmt.Fprintln(os.Stderr, "  gocodec fromjson [-schema GO_FILE] -type TYPE [JSON_FILE] > FILE")
+	fmt.Fprintln(os.Stderr, "  gocodec diff [-schema GO_FILE] -type TYPE [-frame N] FILE_A FILE_B")
+}
+
+// typeFlags are the flags telling the type of the frame
+type typeFlags struct {
+	schema *string
+	expr   *string
+}
+
+func newTypeFlags(flagSet *flag.FlagSet) typeFlags {
+	return typeFlags{
+		schema: flagSet.String("schema", "", "go file declaring the types used by -type"),
+		expr:   flagSet.String("type", "", "go type expression of the frame"),
+	}
+}
+
+func (flags typeFlags) valType() (reflect.Type, error) {
+	schema := newSchema()
+	if *flags.schema != "" {
+		if err := schema.load(*flags.schema); err != nil {
+			return nil, err
+		}
+	}
+	return schema.parseType(*flags.expr)
+}
+
+func parseFlags(flagSet *flag.FlagSet, args []string) error {
+	flagSet.SetOutput(ioutil.Discard)
+	if err := flagSet.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
+
+func list(args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("list", flag.ContinueOnError)
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errUsage
+	}
+	buf, err := ioutil.ReadFile(flagSet.Arg(0))
+	if err != nil {
+		return err
+	}
+	iter := gocodec.NewIterator(buf)
+	offset := 0
+	for i := 0; ; i++ {
+		size := int(iter.NextSize())
+		if size == 0 {
+			break
+		}
+		if size > len(iter.Buffer()) {
+			return fmt.Errorf("frame %d at %d is truncated", i, offset)
+		}
+		fmt.Fprintf(stdout, "#%d offset %d size %d signature 0x%08x\n", i, offset, size, iter.NextSignature())
+		iter.Skip()
+		offset += size
+	}
+	if len(iter.Buffer()) != 0 {
+		fmt.Fprintf(stdout, "%d trailing bytes at %d\n", len(iter.Buffer()), offset)
+	}
+	return nil
+}
+
+func dump(args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("dump", flag.ContinueOnError)
+	typeFlags := newTypeFlags(flagSet)
+	frameIndex := flagSet.Int("frame", 0, "index of the frame to dump")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errUsage
+	}
+	frame, err := readFrame(flagSet.Arg(0), *frameIndex)
+	if err != nil {
+		return err
+	}
+	if *typeFlags.expr == "" {
+		_, err := io.WriteString(stdout, hex.Dump(frame))
+		return err
+	}
+	valType, err := typeFlags.valType()
+	if err != nil {
+		return err
+	}
+	return gocodec.Dump(stdout, frame, reflect.New(valType).Interface())
+}
+
+func toJSON(args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("tojson", flag.ContinueOnError)
+	typeFlags := newTypeFlags(flagSet)
+	frameIndex := flagSet.Int("frame", 0, "index of the frame to convert")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 || *typeFlags.expr == "" {
+		return errUsage
+	}
+	valType, err := typeFlags.valType()
+	if err != nil {
+		return err
+	}
+	frame, err := readFrame(flagSet.Arg(0), *frameIndex)
+	if err != nil {
+		return err
+	}
+	output, err := gocodec.ToJSON(frame, reflect.New(valType).Interface())
+	if err != nil {
+		return err
+	}
+	indented := bytes.NewBuffer(nil)
+	if err := json.Indent(indented, output, "", "  "); err != nil {
+		return err
+	}
+	indented.WriteByte('\n')
+	_, err = indented.WriteTo(stdout)
+	return err
+}
+
+// fromJSON reads json from file or stdin, writes the frame to stdout
+func fromJSON(args []string, stdin io.Reader, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("fromjson", flag.ContinueOnError)
+	typeFlags := newTypeFlags(flagSet)
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() > 1 || *typeFlags.expr == "" {
+		return errUsage
+	}
+	valType, err := typeFlags.valType()
+	if err != nil {
+		return err
+	}
+	var input []byte
+	if flagSet.NArg() == 1 {
+		input, err = ioutil.ReadFile(flagSet.Arg(0))
+	} else {
+		input, err = ioutil.ReadAll(stdin)
+	}
+	if err != nil {
+		return err
+	}
+	frame, err := gocodec.FromJSON(input, reflect.New(valType).Interface())
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(frame)
+	return err
+}
+
+// diff prints the differences and fails if there is any, so that it can be used in regression check
+func diff(args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("diff", flag.ContinueOnError)
+	typeFlags := newTypeFlags(flagSet)
+	frameIndex := flagSet.Int("frame", 0, "index of the frame to compare")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 2 || *typeFlags.expr == "" {
+		return errUsage
+	}
+	valType, err := typeFlags.valType()
+	if err != nil {
+		return err
+	}
+	frameA, err := readFrame(flagSet.Arg(0), *frameIndex)
+	if err != nil {
+		return err
+	}
+	frameB, err := readFrame(flagSet.Arg(1), *frameIndex)
+	if err != nil {
+		return err
+	}
+	differences, err := gocodec.Diff(frameA, frameB, reflect.New(valType).Interface())
+	if err != nil {
+		return err
+	}
+	for _, difference := range differences {
+		fmt.Fprintln(stdout, difference)
+	}
+	if len(differences) != 0 {
+		return fmt.Errorf("%d differences found", len(differences))
+	}
+	return nil
+}
+
+func readFrame(filename string, frameIndex int) ([]byte, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	iter := gocodec.NewIterator(buf)
+	for i := 0; i < frameIndex; i++ {
+		size := int(iter.NextSize())
+		if size == 0 || size > len(iter.Buffer()) {
+			return nil, fmt.Errorf("frame %d not found", frameIndex)
+		}
+		iter.Skip()
+	}
+	size := int(iter.NextSize())
+	if size == 0 || size > len(iter.Buffer()) {
+		return nil, fmt.Errorf("frame %d not found", frameIndex)
+	}
+	return iter.Buffer()[:size], nil
+}
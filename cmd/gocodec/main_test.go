@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+type testOrder struct {
+	Id    int
+	Tags  []string
+	Items []testItem
+}
+
+type testItem struct {
+	Name  string
+	Count uint32
+}
+
+const testSchema = `package orders
+
+type Order struct {
+	Id    int
+	Tags  []string
+	Items []Item
+}
+
+type Item struct {
+	Name  string
+	Count uint32
+}
+`
+
+// writeTestFiles writes a frame for each order into orders.bin, and the schema of them into schema.go
+func writeTestFiles(t *testing.T, orders ...testOrder) string {
+	should := require.New(t)
+	dir, err := ioutil.TempDir("", "gocodec")
+	should.Nil(err)
+	stream := gocodec.NewStream(nil)
+	for _, order := range orders {
+		stream.Marshal(order)
+	}
+	should.Nil(stream.Error)
+	should.Nil(ioutil.WriteFile(filepath.Join(dir, "orders.bin"), stream.Buffer(), 0644))
+	should.Nil(ioutil.WriteFile(filepath.Join(dir, "schema.go"), []byte(testSchema), 0644))
+	return dir
+}
+
+func runCommand(args ...string) (string, error) {
+	output := bytes.NewBuffer(nil)
+	err := run(args, bytes.NewReader(nil), output)
+	return output.String(), err
+}
+
+func Test_list(t *testing.T) {
+	should := require.New(t)
+	dir := writeTestFiles(t, testOrder{Id: 1}, testOrder{Id: 2, Tags: []string{"a"}})
+	defer os.RemoveAll(dir)
+	first, err := gocodec.Marshal(testOrder{Id: 1})
+	should.Nil(err)
+	second, err := gocodec.Marshal(testOrder{Id: 2, Tags: []string{"a"}})
+	should.Nil(err)
+	output, err := runCommand("list", filepath.Join(dir, "orders.bin"))
+	should.Nil(err)
+	should.Contains(output, fmt.Sprintf("#0 offset 0 size %d signature 0x", len(first)))
+	should.Contains(output, fmt.Sprintf("#1 offset %d size %d signature 0x", len(first), len(second)))
+	_, err = runCommand("list")
+	should.Equal(errUsage, err)
+	_, err = runCommand("unknown")
+	should.Equal(errUsage, err)
+}
+
+func Test_dump(t *testing.T) {
+	should := require.New(t)
+	dir := writeTestFiles(t, testOrder{Id: 1}, testOrder{Id: 2, Items: []testItem{{"apple", 3}}})
+	defer os.RemoveAll(dir)
+	output, err := runCommand("dump", "-frame", "1", filepath.Join(dir, "orders.bin"))
+	should.Nil(err)
+	should.Contains(output, "00000000  ")
+	output, err = runCommand("dump", "-frame", "1",
+		"-type", "struct { Id int; Tags []string; Items []struct { Name string; Count uint32 } }",
+		filepath.Join(dir, "orders.bin"))
+	should.Nil(err)
+	should.Contains(output, "Items[0].Name string = \"apple\"")
+	schemaOutput, err := runCommand("dump", "-frame", "1", "-schema", filepath.Join(dir, "schema.go"),
+		"-type", "Order", filepath.Join(dir, "orders.bin"))
+	should.Nil(err)
+	should.Equal(output, schemaOutput)
+	_, err = runCommand("dump", "-frame", "2", filepath.Join(dir, "orders.bin"))
+	should.NotNil(err)
+	_, err = runCommand("dump", "-type", "Item", filepath.Join(dir, "orders.bin"))
+	should.NotNil(err)
+}
+
+func Test_json(t *testing.T) {
+	should := require.New(t)
+	dir := writeTestFiles(t, testOrder{Id: 1, Tags: []string{"a", "b"}, Items: []testItem{{"apple", 3}}})
+	defer os.RemoveAll(dir)
+	output, err := runCommand("tojson", "-schema", filepath.Join(dir, "schema.go"), "-type", "Order",
+		filepath.Join(dir, "orders.bin"))
+	should.Nil(err)
+	should.Contains(output, `"Tags": [`)
+	should.Contains(output, `"Name": "apple"`)
+	should.Nil(ioutil.WriteFile(filepath.Join(dir, "order.json"), []byte(output), 0644))
+	frame, err := runCommand("fromjson", "-schema", filepath.Join(dir, "schema.go"), "-type", "Order",
+		filepath.Join(dir, "order.json"))
+	should.Nil(err)
+	encoded, err := ioutil.ReadFile(filepath.Join(dir, "orders.bin"))
+	should.Nil(err)
+	should.Equal(encoded, []byte(frame))
+	stdout := bytes.NewBuffer(nil)
+	should.Nil(run([]string{"fromjson", "-type", "struct { Id int }"}, bytes.NewBufferString(`{"Id":1}`), stdout))
+	decoded, err := gocodec.Unmarshal(stdout.Bytes(), (*struct{ Id int })(nil))
+	should.Nil(err)
+	should.Equal(1, decoded.(*struct{ Id int }).Id)
+	_, err = runCommand("tojson", filepath.Join(dir, "orders.bin"))
+	should.Equal(errUsage, err)
+}
+
+func Test_diff(t *testing.T) {
+	should := require.New(t)
+	dirA := writeTestFiles(t, testOrder{Id: 1, Tags: []string{"a"}})
+	defer os.RemoveAll(dirA)
+	dirB := writeTestFiles(t, testOrder{Id: 1, Tags: []string{"b"}})
+	defer os.RemoveAll(dirB)
+	args := []string{"diff", "-schema", filepath.Join(dirA, "schema.go"), "-type", "Order"}
+	output, err := runCommand(append(args, filepath.Join(dirA, "orders.bin"), filepath.Join(dirA, "orders.bin"))...)
+	should.Nil(err)
+	should.Equal("", output)
+	output, err = runCommand(append(args, filepath.Join(dirA, "orders.bin"), filepath.Join(dirB, "orders.bin"))...)
+	should.NotNil(err)
+	should.Equal("Tags[0]: \"a\" != \"b\"\n", output)
+}
+
+func Test_schema(t *testing.T) {
+	should := require.New(t)
+	dir, err := ioutil.TempDir("", "gocodec")
+	should.Nil(err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "schema.go")
+	should.Nil(ioutil.WriteFile(filename, []byte(`package schema
+
+import "time"
+
+type Node struct {
+	Next *Node
+}
+
+type Event struct {
+	At   time.Time
+	Tags Tags
+	lock [8]byte `+"`gocodec:\"-\"`"+`
+}
+
+type Tags []string
+
+type int struct{ X uint8 }
+`), 0644))
+	schema := newSchema()
+	should.Nil(schema.load(filename))
+	valType, err := schema.parseType("[]Event")
+	should.Nil(err)
+	should.Equal("[]struct { At time.Time; Tags []string; lock [8]uint8 \"gocodec:\\\"-\\\"\" }", valType.String())
+	// the declared type shadows the builtin one
+	valType, err = schema.parseType("int")
+	should.Nil(err)
+	should.Equal("struct { X uint8 }", valType.String())
+	_, err = schema.parseType("Node")
+	should.NotNil(err)
+	should.Contains(err.Error(), "recursive type Node")
+	_, err = schema.parseType("Unknown")
+	should.NotNil(err)
+}
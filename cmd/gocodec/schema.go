@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var builtinTypes = map[string]reflect.Type{
+	"int":        reflect.TypeOf(int(0)),
+	"int8":       reflect.TypeOf(int8(0)),
+	"int16":      reflect.TypeOf(int16(0)),
+	"int32":      reflect.TypeOf(int32(0)),
+	"int64":      reflect.TypeOf(int64(0)),
+	"uint":       reflect.TypeOf(uint(0)),
+	"uint8":      reflect.TypeOf(uint8(0)),
+	"uint16":     reflect.TypeOf(uint16(0)),
+	"uint32":     reflect.TypeOf(uint32(0)),
+	"uint64":     reflect.TypeOf(uint64(0)),
+	"uintptr":    reflect.TypeOf(uintptr(0)),
+	"byte":       reflect.TypeOf(byte(0)),
+	"rune":       reflect.TypeOf(rune(0)),
+	"float32":    reflect.TypeOf(float32(0)),
+	"float64":    reflect.TypeOf(float64(0)),
+	"complex64":  reflect.TypeOf(complex64(0)),
+	"complex128": reflect.TypeOf(complex128(0)),
+	"bool":       reflect.TypeOf(false),
+	"string":     reflect.TypeOf(""),
+}
+
+var qualifiedTypes = map[string]reflect.Type{
+	"time.Time": reflect.TypeOf(time.Time{}),
+}
+
+// schema is the type declarations of a go file, which the type expression can refer to by name
+type schema struct {
+	decls map[string]ast.Expr
+	types map[string]reflect.Type
+	// the declared types being built, to tell recursive type
+	building map[string]bool
+}
+
+func newSchema() *schema {
+	return &schema{decls: map[string]ast.Expr{}, types: map[string]reflect.Type{}, building: map[string]bool{}}
+}
+
+// load adds the types declared in the go file, only the declarations are read, the file does not need to compile
+func (schema *schema) load(filename string) error {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, nil, 0)
+	if err != nil {
+		return err
+	}
+	for _, decl := range file.Decls {
+		genDecl, isGenDecl := decl.(*ast.GenDecl)
+		if !isGenDecl || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			if typeSpec.TypeParams != nil {
+				return fmt.Errorf("%s: generic type %s is not supported", filename, typeSpec.Name.Name)
+			}
+			schema.decls[typeSpec.Name.Name] = typeSpec.Type
+		}
+	}
+	return nil
+}
+
+// parseType builds the type from go type expression like "struct { Id int; Tags []string }".
+// The signature only depends on the memory layout, so the type does not need to be the original one,
+// as long as the fields are declared in the same order with the same types.
+func (schema *schema) parseType(expr string) (reflect.Type, error) {
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	return schema.typeOfExpr(node)
+}
+
+// typeOfName looks up the declared type first, as it shadows the builtin type of the same name
+func (schema *schema) typeOfName(name string) (reflect.Type, error) {
+	if valType := schema.types[name]; valType != nil {
+		return valType, nil
+	}
+	decl := schema.decls[name]
+	if decl == nil {
+		valType := builtinTypes[name]
+		if valType == nil {
+			return nil, fmt.Errorf("unknown type %s", name)
+		}
+		return valType, nil
+	}
+	if schema.building[name] {
+		return nil, fmt.Errorf("recursive type %s is not supported", name)
+	}
+	schema.building[name] = true
+	defer delete(schema.building, name)
+	valType, err := schema.typeOfExpr(decl)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	schema.types[name] = valType
+	return valType, nil
+}
+
+func (schema *schema) typeOfExpr(node ast.Expr) (reflect.Type, error) {
+	switch node := node.(type) {
+	case *ast.Ident:
+		return schema.typeOfName(node.Name)
+	case *ast.SelectorExpr:
+		pkg, isIdent := node.X.(*ast.Ident)
+		if !isIdent {
+			return nil, fmt.Errorf("unknown type")
+		}
+		valType := qualifiedTypes[pkg.Name+"."+node.Sel.Name]
+		if valType == nil {
+			return nil, fmt.Errorf("unknown type %s.%s", pkg.Name, node.Sel.Name)
+		}
+		return valType, nil
+	case *ast.ParenExpr:
+		return schema.typeOfExpr(node.X)
+	case *ast.StarExpr:
+		elemType, err := schema.typeOfExpr(node.X)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PtrTo(elemType), nil
+	case *ast.ArrayType:
+		elemType, err := schema.typeOfExpr(node.Elt)
+		if err != nil {
+			return nil, err
+		}
+		if node.Len == nil {
+			return reflect.SliceOf(elemType), nil
+		}
+		lit, isLit := node.Len.(*ast.BasicLit)
+		if !isLit || lit.Kind != token.INT {
+			return nil, fmt.Errorf("array length must be integer literal")
+		}
+		length, err := strconv.Atoi(lit.Value)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ArrayOf(length, elemType), nil
+	case *ast.StructType:
+		var fields []reflect.StructField
+		for _, field := range node.Fields.List {
+			fieldType, err := schema.typeOfExpr(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			if len(field.Names) == 0 {
+				return nil, fmt.Errorf("embedded field is not supported")
+			}
+			for _, name := range field.Names {
+				structField := reflect.StructField{Name: name.Name, Type: fieldType}
+				if !name.IsExported() {
+					structField.PkgPath = "main"
+				}
+				if field.Tag != nil {
+					tag, err := strconv.Unquote(field.Tag.Value)
+					if err != nil {
+						return nil, err
+					}
+					structField.Tag = reflect.StructTag(tag)
+				}
+				fields = append(fields, structField)
+			}
+		}
+		return reflect.StructOf(fields), nil
+	}
+	return nil, fmt.Errorf("unsupported type expression %T", node)
+}
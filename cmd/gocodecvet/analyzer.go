@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"reflect"
+	"strings"
+)
+
+const gocodecPath = "github.com/esdb/gocodec"
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "gocodecvet",
+	Doc:      "check the types of values passed to gocodec, with the rules gocodec uses to create its codecs",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+var binaryMarshaler bool
+var codecs string
+
+func init() {
+	Analyzer.Flags.BoolVar(&binaryMarshaler, "binarymarshaler", false,
+		"types implementing encoding.BinaryMarshaler and BinaryUnmarshaler are supported, as with Config.BinaryMarshaler")
+	Analyzer.Flags.StringVar(&codecs, "codecs", "",
+		"comma separated types registered with Config.RegisterCodec, such as example.com/pkg.Type")
+}
+
+// encodingFuncs take the value to encode as the last argument,
+// the method is named with its receiver when the name is shared, as ValEncoder has Encode too
+var encodingFuncs = map[string]bool{
+	"Marshal":        true,
+	"MarshalTo":      true,
+	"EncodedSize":    true,
+	"Hash":           true,
+	"Encoder.Encode": true,
+}
+
+// pointerFuncs take the pointers to the values, such as candidate pointers, as the arguments of type interface{}
+var pointerFuncs = map[string]bool{
+	"MarshalPointer":              true,
+	"Unmarshal":                   true,
+	"UnmarshalCandidates":         true,
+	"UnmarshalInto":               true,
+	"CopyThenUnmarshal":           true,
+	"CopyThenUnmarshalCandidates": true,
+	"EncodePointer":               true,
+	"HashFrame":                   true,
+	"Dump":                        true,
+	"ToJSON":                      true,
+	"FromJSON":                    true,
+	"Diff":                        true,
+	"Register":                    true,
+	"NewSharedView":               true,
+}
+
+// handlerFuncs take the func(*T) handling the frames of T
+var handlerFuncs = map[string]bool{
+	"Mux.Handle": true,
+}
+
+// runtimeStateTypes are accepted by gocodec as plain memory, but mean nothing once decoded elsewhere.
+// Besides these, every type of package sync and every type with its own Lock and Unlock is reported.
+var runtimeStateTypes = map[string]bool{
+	"os.File":    true,
+	"os.Process": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	checker := &typeChecker{pass: pass, registered: map[string]bool{}}
+	for _, name := range strings.Split(codecs, ",") {
+		if name != "" {
+			checker.registered[name] = true
+		}
+	}
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(node ast.Node) {
+		call := node.(*ast.CallExpr)
+		fn := calleeOf(pass, call)
+		if fn == nil || len(call.Args) == 0 {
+			return
+		}
+		name := fn.Name()
+		if recv := receiverNameOf(fn); recv != "" && (encodingFuncs[recv+"."+name] || handlerFuncs[recv+"."+name]) {
+			name = recv + "." + name
+		}
+		switch {
+		case encodingFuncs[name]:
+			arg := call.Args[len(call.Args)-1]
+			checker.check(arg, pass.TypesInfo.TypeOf(arg))
+		case handlerFuncs[name]:
+			arg := call.Args[0]
+			handler, isFunc := pass.TypesInfo.TypeOf(arg).Underlying().(*types.Signature)
+			if !isFunc || handler.Params().Len() != 1 {
+				return
+			}
+			if ptrType, isPtr := handler.Params().At(0).Type().(*types.Pointer); isPtr {
+				checker.check(arg, ptrType.Elem())
+			}
+		case pointerFuncs[name]:
+			signature := fn.Type().(*types.Signature)
+			for i, arg := range call.Args {
+				if !isEmptyInterface(paramTypeOf(signature, i)) {
+					continue
+				}
+				if ptrType, isPtr := pass.TypesInfo.TypeOf(arg).(*types.Pointer); isPtr {
+					checker.check(arg, ptrType.Elem())
+				}
+			}
+		}
+	})
+	return nil, nil
+}
+
+// calleeOf returns the gocodec function or method called, nil if the call is not to gocodec
+func calleeOf(pass *analysis.Pass, call *ast.CallExpr) *types.Func {
+	var ident *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return nil
+	}
+	fn, isFunc := pass.TypesInfo.Uses[ident].(*types.Func)
+	if !isFunc || fn.Pkg() == nil || fn.Pkg().Path() != gocodecPath {
+		return nil
+	}
+	return fn
+}
+
+// receiverNameOf returns the type name of the method receiver, empty for function
+func receiverNameOf(fn *types.Func) string {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return ""
+	}
+	recvType := recv.Type()
+	if ptrType, isPtr := recvType.(*types.Pointer); isPtr {
+		recvType = ptrType.Elem()
+	}
+	if named, isNamed := types.Unalias(recvType).(*types.Named); isNamed {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+func paramTypeOf(signature *types.Signature, i int) types.Type {
+	params := signature.Params()
+	if signature.Variadic() && i >= params.Len()-1 {
+		return params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+	}
+	if i >= params.Len() {
+		return nil
+	}
+	return params.At(i).Type()
+}
+
+func isEmptyInterface(valType types.Type) bool {
+	if valType == nil {
+		return false
+	}
+	iface, isInterface := valType.Underlying().(*types.Interface)
+	return isInterface && iface.NumMethods() == 0
+}
+
+type typeChecker struct {
+	pass       *analysis.Pass
+	registered map[string]bool
+}
+
+// check reports every problem of valType at the argument, the value of interface type is left to run time
+func (checker *typeChecker) check(arg ast.Expr, valType types.Type) {
+	if valType == nil {
+		return
+	}
+	valType = types.Unalias(valType)
+	if _, isInterface := valType.Underlying().(*types.Interface); isInterface {
+		return
+	}
+	if basic, isBasic := valType.(*types.Basic); isBasic && basic.Info()&types.IsUntyped != 0 {
+		return
+	}
+	for _, problem := range checker.walk(valType, "", nil) {
+		checker.pass.Reportf(arg.Pos(), "gocodec can not handle %s: %s", checker.typeString(valType), problem)
+	}
+}
+
+// walk follows createEncoderOfType, the path is formatted the same as the errors of gocodec
+func (checker *typeChecker) walk(valType types.Type, path string, visiting []types.Type) []string {
+	// type T = sync.Mutex is checked as sync.Mutex
+	valType = types.Unalias(valType)
+	if named, isNamed := valType.(*types.Named); isNamed {
+		qualifiedName := qualifiedNameOf(named)
+		if checker.registered[qualifiedName] || qualifiedName == "time.Time" {
+			return nil
+		}
+		if binaryMarshaler && checker.isBinaryMarshaler(named) {
+			return nil
+		}
+		if holdsRuntimeState(named) {
+			return []string{fmt.Sprintf("Root%s: %s holds runtime state, tag the field with gocodec:\"-\"",
+				path, checker.typeString(valType))}
+		}
+		for _, visited := range visiting {
+			if types.Identical(visited, valType) {
+				return []string{fmt.Sprintf("Root%s: recursive type %s is not supported",
+					path, checker.typeString(valType))}
+			}
+		}
+		visiting = append(visiting, valType)
+	}
+	switch underlying := valType.Underlying().(type) {
+	case *types.Basic:
+		if underlying.Kind() == types.UnsafePointer {
+			return []string{fmt.Sprintf("Root%s: unsupported type %s", path, checker.typeString(valType))}
+		}
+		return nil
+	case *types.Struct:
+		var problems []string
+		for i := 0; i < underlying.NumFields(); i++ {
+			field := underlying.Field(i)
+			fieldPath := path + "." + field.Name()
+			switch tag := reflect.StructTag(underlying.Tag(i)).Get("gocodec"); tag {
+			case "-":
+				continue
+			case "", "copy":
+			default:
+				problems = append(problems, fmt.Sprintf("Root%s: unknown gocodec tag %q", fieldPath, tag))
+				continue
+			}
+			problems = append(problems, checker.walk(field.Type(), fieldPath, visiting)...)
+		}
+		return problems
+	case *types.Array:
+		return checker.walk(underlying.Elem(), path+"[]", visiting)
+	case *types.Slice:
+		return checker.walk(underlying.Elem(), path+"[]", visiting)
+	case *types.Pointer:
+		return checker.walk(underlying.Elem(), path, visiting)
+	}
+	return []string{fmt.Sprintf("Root%s: unsupported type %s", path, checker.typeString(valType))}
+}
+
+// isBinaryMarshaler mirrors the rule of Config.BinaryMarshaler, the value must be able to hold a word
+func (checker *typeChecker) isBinaryMarshaler(named *types.Named) bool {
+	switch named.Underlying().(type) {
+	case *types.Pointer, *types.Interface:
+		return false
+	}
+	methods := types.NewMethodSet(types.NewPointer(named))
+	return checker.pass.TypesSizes.Sizeof(named) >= 8 &&
+		methods.Lookup(named.Obj().Pkg(), "MarshalBinary") != nil &&
+		methods.Lookup(named.Obj().Pkg(), "UnmarshalBinary") != nil
+}
+
+func (checker *typeChecker) typeString(valType types.Type) string {
+	return types.TypeString(valType, types.RelativeTo(checker.pass.Pkg))
+}
+
+func qualifiedNameOf(named *types.Named) string {
+	if named.Obj().Pkg() == nil {
+		return named.Obj().Name()
+	}
+	return named.Obj().Pkg().Path() + "." + named.Obj().Name()
+}
+
+// holdsRuntimeState tells if the type is a lock or something alike, the embedded lock is reported at its field
+func holdsRuntimeState(named *types.Named) bool {
+	if named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "sync" {
+		return true
+	}
+	if runtimeStateTypes[qualifiedNameOf(named)] {
+		return true
+	}
+	methods := types.NewMethodSet(types.NewPointer(named))
+	lock := methods.Lookup(named.Obj().Pkg(), "Lock")
+	unlock := methods.Lookup(named.Obj().Pkg(), "Unlock")
+	return lock != nil && unlock != nil && len(lock.Index()) == 1 && len(unlock.Index()) == 1
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func Test_analyzer(t *testing.T) {
+	// aliases are type checked as *types.Alias, whatever go version the test is built with
+	t.Setenv("GODEBUG", "gotypesalias=1")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a")
+}
+
+func Test_analyzer_codecs(t *testing.T) {
+	if err := Analyzer.Flags.Set("codecs", "b.Registered"); err != nil {
+		t.Fatal(err)
+	}
+	defer Analyzer.Flags.Set("codecs", "")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "b")
+}
@@ -0,0 +1,18 @@
+// gocodecvet reports the values passed to gocodec that can not be encoded, or should not be
+//
+//	gocodecvet [-binarymarshaler] [-codecs TYPE,...] PACKAGE...
+//
+// The static type of the value given to Marshal, MarshalPointer, MarshalTo, EncodedSize, Hash, Unmarshal,
+// UnmarshalCandidates, UnmarshalInto and their Iterator and Stream counterparts is checked with the rules gocodec uses
+// to create its codecs. Values of interface type are not checked, as their types are only known at run time.
+// Besides the unsupported types, the fields holding runtime state, such as sync.Mutex or os.File,
+// are reported, as they are encoded as raw memory; tag them with gocodec:"-" to leave them out.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(Analyzer)
+}
@@ -0,0 +1,59 @@
+package a
+
+import (
+	"sync"
+
+	"github.com/esdb/gocodec"
+)
+
+type WithMutex struct {
+	Field1 int
+	lock   sync.Mutex
+}
+
+type WithChan struct {
+	Field1 chan int
+}
+
+type Skipped struct {
+	Field1 int
+	lock   sync.Mutex `gocodec:"-"`
+	notify chan int   `gocodec:"-"`
+}
+
+type Node struct {
+	Value int
+	Next  *Node
+}
+
+type Mutex = sync.Mutex
+
+type WithAlias struct {
+	lock Mutex
+}
+
+func marshal() {
+	gocodec.Marshal(WithMutex{}) // want `gocodec can not handle WithMutex: Root.lock: sync.Mutex holds runtime state, tag the field with gocodec:"-"`
+	gocodec.Marshal(WithChan{})  // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.Marshal(Skipped{})
+	gocodec.Marshal(&WithAlias{})        // want `gocodec can not handle \*WithAlias: Root.lock: sync.Mutex holds runtime state`
+	gocodec.Unmarshal(nil, (*Node)(nil)) // want `gocodec can not handle Node: Root.Next: recursive type Node is not supported`
+	var val interface{} = WithChan{}
+	gocodec.Marshal(val)
+}
+
+func entryPoints(encoder gocodec.ValEncoder, stream *gocodec.Stream) {
+	gocodec.NewEncoder().Encode(WithChan{})                       // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.NewEncoder().EncodePointer(&WithChan{})               // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.HashFrame(nil, (*WithChan)(nil))                      // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.Dump(nil, (*WithChan)(nil))                           // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.ToJSON(nil, (*WithChan)(nil))                         // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.FromJSON(nil, (*WithChan)(nil))                       // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.Diff(nil, nil, (*WithChan)(nil))                      // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.NewRegistry().Register((*Skipped)(nil), (*Node)(nil)) // want `gocodec can not handle Node: Root.Next: recursive type Node is not supported`
+	gocodec.NewSharedView(nil, (*WithChan)(nil))                  // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.NewMux().Handle(func(val *WithChan) {})               // want `gocodec can not handle WithChan: Root.Field1: unsupported type chan int`
+	gocodec.NewMux().Handle(func(val *Skipped) {})
+	// not Encoder.Encode, the stream is not the value to encode
+	encoder.Encode(nil, stream)
+}
@@ -0,0 +1,19 @@
+package b
+
+import (
+	"github.com/esdb/gocodec"
+)
+
+// Registered has its own codec, registered with Config.RegisterCodec
+type Registered struct {
+	notify chan int
+}
+
+type Unregistered struct {
+	notify chan int
+}
+
+func marshal() {
+	gocodec.Marshal(Registered{})
+	gocodec.Marshal([]Unregistered{}) // want `gocodec can not handle \[\]Unregistered: Root\[\].notify: unsupported type chan int`
+}
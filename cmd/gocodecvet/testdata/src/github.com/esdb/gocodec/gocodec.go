@@ -0,0 +1,78 @@
+// Package gocodec is the part of github.com/esdb/gocodec the analyzer looks for
+package gocodec
+
+func Marshal(val interface{}) ([]byte, error) {
+	return nil, nil
+}
+
+func Unmarshal(buf []byte, candidatePointer interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+type Stream struct {
+	Error error
+}
+
+type ValEncoder interface {
+	Encode(ptr *int, stream *Stream)
+}
+
+type Encoder struct{}
+
+func NewEncoder() *Encoder {
+	return nil
+}
+
+func (encoder *Encoder) Encode(val interface{}) error {
+	return nil
+}
+
+func (encoder *Encoder) EncodePointer(ptr interface{}) error {
+	return nil
+}
+
+func HashFrame(buf []byte, candidatePointer interface{}) ([]byte, error) {
+	return nil, nil
+}
+
+func Dump(buf []byte, candidatePointer interface{}) error {
+	return nil
+}
+
+func ToJSON(buf []byte, candidatePointer interface{}) ([]byte, error) {
+	return nil, nil
+}
+
+func FromJSON(data []byte, candidatePointer interface{}) ([]byte, error) {
+	return nil, nil
+}
+
+func Diff(a []byte, b []byte, candidatePointer interface{}) error {
+	return nil
+}
+
+type Registry struct{}
+
+func NewRegistry() *Registry {
+	return nil
+}
+
+func (registry *Registry) Register(candidatePointers ...interface{}) error {
+	return nil
+}
+
+type Mux struct{}
+
+func NewMux() *Mux {
+	return nil
+}
+
+func (mux *Mux) Handle(handler interface{}) error {
+	return nil
+}
+
+type SharedView struct{}
+
+func NewSharedView(buf []byte, candidatePointers ...interface{}) (*SharedView, error) {
+	return nil, nil
+}
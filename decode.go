@@ -15,9 +15,11 @@ type Iterator struct {
 	objectSeq ObjectSeq
 	cfg       *frozenConfig
 	buf       []byte
-	self      []byte
-	cursor    []byte
-	Error     error
+	// the frame being frozen, while iter.buf is its scratch copy
+	origin []byte
+	self   []byte
+	cursor []byte
+	Error  error
 }
 
 func (cfg *frozenConfig) NewIterator(buf []byte) *Iterator {
@@ -31,10 +33,20 @@ func (iter *Iterator) Reset(buf []byte) {
 }
 
 func (iter *Iterator) NextSize() uint32 {
-	if len(iter.buf) < 8 {
+	if len(iter.buf) < frameHeaderSize {
 		return 0
 	}
-	return *(*uint32)(unsafe.Pointer(&iter.buf[0]))
+	return frameSize(iter.buf)
+}
+
+// NextSignature is the signature word in the header of next frame.
+// The plain frame decoded in place keeps its state in the word, so it only reads as the signature
+// of the type again after Freeze.
+func (iter *Iterator) NextSignature() uint32 {
+	if len(iter.buf) < frameHeaderSize {
+		return 0
+	}
+	return frameSignature(iter.buf)
 }
 
 func (iter *Iterator) Skip() []byte {
@@ -72,6 +84,21 @@ func (iter *Iterator) CopyThenUnmarshalCandidates(candidatePointers ...interface
 	return result
 }
 
+var errOnHeapWithRelocationTable = errors.New("value decoded onto heap can not be read from frame with relocation table")
+
+// allocate copies the values to be decoded, either by the allocator, or onto heap as length values of valType,
+// so that the pointers written into them are seen by garbage collector.
+// The copy onto heap is typed, the pointers are later stored by setPointer, both go through write barrier.
+func (iter *Iterator) allocate(onHeap bool, valType reflect.Type, length int, original []byte) []byte {
+	if !onHeap {
+		return iter.allocator.Allocate(iter.objectSeq, original)
+	}
+	copied := reflect.MakeSlice(reflect.SliceOf(valType), length, length)
+	src := sliceReadonlyHeader{Data: unsafe.Pointer(&original[0]), Len: length, Cap: length}
+	reflect.Copy(copied, reflect.NewAt(copied.Type(), unsafe.Pointer(&src)).Elem())
+	return ptrAsBytes(len(original), unsafe.Pointer(copied.Pointer()))
+}
+
 func (iter *Iterator) ObjectSeq(objectSeq ObjectSeq) {
 	iter.objectSeq = objectSeq
 }
@@ -86,6 +113,10 @@ func (iter *Iterator) Unmarshal(candidatePointer interface{}) interface{} {
 		iter.Error = io.EOF
 		return nil
 	}
+	if err := checkFrame(iter.buf); err != nil {
+		iter.ReportError("Unmarshal", err)
+		return nil
+	}
 	thisBuf := iter.buf[:size]
 	defer func() {
 		recovered := recover()
@@ -98,7 +129,6 @@ func (iter *Iterator) Unmarshal(candidatePointer interface{}) interface{} {
 		}
 	}()
 	nextBuf := iter.buf[size:]
-	sig := *(*uint32)(unsafe.Pointer(&iter.buf[4]))
 	var decoder RootDecoder
 	var val interface{}
 	valType := reflect.TypeOf(candidatePointer).Elem()
@@ -107,8 +137,8 @@ func (iter *Iterator) Unmarshal(candidatePointer interface{}) interface{} {
 		iter.ReportError("DecodeVal", err)
 		return nil
 	}
-	if tryDecoder.Signature() != sig {
-		iter.ReportError("DecodeVal", errors.New("no decoder matches the signature"))
+	if !frameHolds(thisBuf, tryDecoder.Signature()) {
+		iter.ReportError("DecodeVal", errSignatureMismatch)
 		return nil
 	}
 	decoder = tryDecoder
@@ -124,6 +154,10 @@ func (iter *Iterator) UnmarshalCandidates(candidatePointers ...interface{}) inte
 		iter.Error = io.EOF
 		return nil
 	}
+	if err := checkFrame(iter.buf); err != nil {
+		iter.ReportError("Unmarshal", err)
+		return nil
+	}
 	thisBuf := iter.buf[:size]
 	defer func() {
 		recovered := recover()
@@ -136,7 +170,6 @@ func (iter *Iterator) UnmarshalCandidates(candidatePointers ...interface{}) inte
 		}
 	}()
 	nextBuf := iter.buf[size:]
-	sig := *(*uint32)(unsafe.Pointer(&iter.buf[4]))
 	var decoder RootDecoder
 	var val interface{}
 	for _, candidatePointer := range candidatePointers {
@@ -146,14 +179,14 @@ func (iter *Iterator) UnmarshalCandidates(candidatePointers ...interface{}) inte
 			iter.ReportError("DecodeVal", err)
 			return nil
 		}
-		if tryDecoder.Signature() == sig {
+		if frameHolds(thisBuf, tryDecoder.Signature()) {
 			decoder = tryDecoder
 			val = candidatePointer
 			break
 		}
 	}
 	if decoder == nil {
-		iter.ReportError("DecodeVal", errors.New("no decoder matches the signature"))
+		iter.ReportError("DecodeVal", errSignatureMismatch)
 		return nil
 	}
 	decoder.DecodeEmptyInterface((*emptyInterface)(unsafe.Pointer(&val)), iter)
@@ -161,6 +194,193 @@ func (iter *Iterator) UnmarshalCandidates(candidatePointers ...interface{}) inte
 	return val
 }
 
+// UnmarshalRegistered decodes next frame as the type registered with its signature
+func (iter *Iterator) UnmarshalRegistered(registry *Registry) interface{} {
+	size := iter.NextSize()
+	if size == 0 {
+		iter.Error = io.EOF
+		return nil
+	}
+	if err := checkFrame(iter.buf); err != nil {
+		iter.ReportError("Unmarshal", err)
+		return nil
+	}
+	thisBuf := iter.buf[:size]
+	defer func() {
+		recovered := recover()
+		if recovered != nil {
+			countlog.Fatal("event!gocodec.failed to unmarshal",
+				"err", recovered,
+				"buf", hex.EncodeToString(thisBuf),
+				"stacktrace", countlog.ProvideStacktrace)
+			iter.ReportError("Unmarshal", fmt.Errorf("%v", recovered))
+		}
+	}()
+	nextBuf := iter.buf[size:]
+	entry, found := registry.lookupFrame(thisBuf)
+	if !found {
+		iter.ReportError("DecodeVal", errSignatureMismatch)
+		return nil
+	}
+	val := entry.candidatePointer
+	entry.decoder.DecodeEmptyInterface((*emptyInterface)(unsafe.Pointer(&val)), iter)
+	iter.buf = nextBuf
+	return val
+}
+
+var errFreezeReadonly = errors.New("frame is never decoded in place by readonly config, it is already portable")
+
+// Freeze turns next frame decoded in place back to the portable form, so that it can be written out again.
+// Only fixed size fields can be changed after decoding, pointers must still point into the frame.
+// If any pointer can not be frozen, the error is reported and the frame is left decoded as it was.
+func (iter *Iterator) Freeze(candidatePointer interface{}) {
+	size := iter.NextSize()
+	if size == 0 {
+		iter.Error = io.EOF
+		return
+	}
+	if err := checkFrame(iter.buf); err != nil {
+		iter.ReportError("Freeze", err)
+		return
+	}
+	thisBuf := iter.buf[:size]
+	defer func() {
+		recovered := recover()
+		if recovered != nil {
+			countlog.Fatal("event!gocodec.failed to freeze",
+				"err", recovered,
+				"buf", hex.EncodeToString(thisBuf),
+				"stacktrace", countlog.ProvideStacktrace)
+			iter.ReportError("Freeze", fmt.Errorf("%v", recovered))
+		}
+	}()
+	if iter.cfg.readonlyDecode {
+		iter.ReportError("Freeze", errFreezeReadonly)
+		return
+	}
+	nextBuf := iter.buf[size:]
+	valType := reflect.TypeOf(candidatePointer).Elem()
+	decoder, err := decoderOfType(iter.cfg, valType)
+	if err != nil {
+		iter.ReportError("Freeze", err)
+		return
+	}
+	if !frameHolds(thisBuf, decoder.Signature()) {
+		iter.ReportError("Freeze", errSignatureMismatch)
+		return
+	}
+	decoder.Freeze(iter)
+	iter.buf = nextBuf
+}
+
+// Word reads the word at offset within the portable form of the value being decoded,
+// it is meant for the codec registered with Config.RegisterCodec
+func (iter *Iterator) Word(offset uintptr) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&iter.cursor[offset]))
+}
+
+// OutOfLine returns length bytes written by Stream.WriteOutOfLine, the word at offset points to.
+// nil is returned if the word is 0, or reported as error if the bytes are not within the frame.
+func (iter *Iterator) OutOfLine(offset uintptr, length uintptr) []byte {
+	relOffset := iter.Word(offset)
+	if relOffset == 0 {
+		return nil
+	}
+	frame := iter.buf[:iter.NextSize()]
+	start := uintptr(unsafe.Pointer(&iter.cursor[offset])) + relOffset - uintptr(unsafe.Pointer(&frame[0]))
+	if start+length < start || start+length > uintptr(len(frame)) {
+		iter.ReportError("OutOfLine", errPointerOutOfFrame)
+		return nil
+	}
+	return frame[start : start+length : start+length]
+}
+
+// SetWord writes the word at offset within the decoded value
+func (iter *Iterator) SetWord(offset uintptr, word uintptr) {
+	*(*uintptr)(unsafe.Pointer(&iter.self[offset])) = word
+}
+
+// SetPointer makes the word at offset within the decoded value point to target,
+// target must be within the frame, as the frame memory is not scanned by garbage collector
+func (iter *Iterator) SetPointer(offset uintptr, target []byte) {
+	iter.setPointer(offset, unsafe.Pointer(&target[0]))
+}
+
+// setPointer stores the pointer as unsafe.Pointer, never as uintptr,
+// as the decoded value might be on heap, where the store must go through write barrier
+func (iter *Iterator) setPointer(offset uintptr, ptr unsafe.Pointer) {
+	*(*unsafe.Pointer)(unsafe.Pointer(&iter.self[offset])) = ptr
+}
+
+// FreezePointer turns the pointer at offset within the decoded value back to the relative offset,
+// it is meant for the Freeze of the codec registered with Config.RegisterCodec
+func (iter *Iterator) FreezePointer(offset uintptr) {
+	cursor := iter.cursor
+	iter.cursor = iter.cursor[offset:]
+	pWord := (*uintptr)(unsafe.Pointer(&iter.cursor[0]))
+	*pWord = iter.relOffsetOf(*pWord)
+	iter.cursor = cursor
+}
+
+// relOffsetOf converts pointer back to offset relative to the cursor, 0 means nil.
+// The cursor is within the scratch copy, so its position is translated to the frame the pointer points into.
+func (iter *Iterator) relOffsetOf(ptr uintptr) uintptr {
+	if ptr == 0 {
+		return 0
+	}
+	origin := uintptr(unsafe.Pointer(&iter.origin[0]))
+	word := origin + uintptr(unsafe.Pointer(&iter.cursor[0])) - uintptr(unsafe.Pointer(&iter.buf[0]))
+	end := origin + uintptr(len(iter.origin))
+	if ptr <= word || ptr > end {
+		iter.ReportError("Freeze", errPointerOutOfFrame)
+		return 0
+	}
+	return ptr - word
+}
+
+var errRelocateReadonly = errors.New("readonly config never decodes in place, the frame must be left as it is")
+
+// Relocate turns the relative offsets of next frame into absolute pointers in place, without knowing its type.
+// Relocating a frame more than once is a no-op.
+// The frame must be encoded with relocation table, the returned bytes start with the root value.
+func (iter *Iterator) Relocate() []byte {
+	size := iter.NextSize()
+	if size == 0 {
+		iter.Error = io.EOF
+		return nil
+	}
+	if err := checkFrame(iter.buf); err != nil {
+		iter.ReportError("Relocate", err)
+		return nil
+	}
+	if !frameHasRelocationTable(iter.buf) {
+		iter.ReportError("Relocate", errNoRelocationTable)
+		return nil
+	}
+	if iter.cfg.readonlyDecode {
+		iter.ReportError("Relocate", errRelocateReadonly)
+		return nil
+	}
+	thisBuf := iter.buf[:size]
+	signature := frameSignature(thisBuf)
+	switch err := switchFrameState(thisBuf, signature, frameStatePortable, frameStateDecoding); err {
+	case nil:
+		if err := relocateFrame(thisBuf); err != nil {
+			// the table is validated before any change, the frame is still portable
+			switchFrameState(thisBuf, signature, frameStateDecoding, frameStatePortable)
+			iter.ReportError("Relocate", err)
+			return nil
+		}
+		switchFrameState(thisBuf, signature, frameStateDecoding, frameStateRelocated)
+	case errFrameRelocated:
+	default:
+		iter.ReportError("Relocate", err)
+		return nil
+	}
+	iter.buf = iter.buf[size:]
+	return thisBuf[frameExtendedHeaderSize:]
+}
+
 func (iter *Iterator) ReportError(operation string, err error) {
 	if iter.Error != nil {
 		return
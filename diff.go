@@ -0,0 +1,162 @@
+package gocodec
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// Difference is a value that is not the same in the two frames compared by Diff
+type Difference struct {
+	Path string
+	A    string
+	B    string
+}
+
+func (difference Difference) String() string {
+	path := difference.Path
+	if path == "" {
+		path = "."
+	}
+	return fmt.Sprintf("%s: %s != %s", path, difference.A, difference.B)
+}
+
+func Diff(a []byte, b []byte, candidatePointer interface{}) ([]Difference, error) {
+	return DefaultConfig.Diff(a, b, candidatePointer)
+}
+
+// Diff compares the first frame of a and b value by value, out of line layout and padding are ignored.
+// Slices of different length are reported once, the elements they have in common are still compared.
+func (cfg *frozenConfig) Diff(a []byte, b []byte, candidatePointer interface{}) ([]Difference, error) {
+	valType := reflect.TypeOf(candidatePointer).Elem()
+	rootA, err := cfg.rootFrameValue(a, valType)
+	if err != nil {
+		return nil, err
+	}
+	rootB, err := cfg.rootFrameValue(b, valType)
+	if err != nil {
+		return nil, err
+	}
+	differ := &frameDiffer{}
+	differ.diff(rootA, rootB, "")
+	return differ.differences, differ.err
+}
+
+type frameDiffer struct {
+	differences []Difference
+	err         error
+}
+
+func (differ *frameDiffer) report(path string, a string, b string) {
+	differ.differences = append(differ.differences, Difference{Path: path, A: a, B: b})
+}
+
+// diff compares the values of the same encoder in the two frames
+func (differ *frameDiffer) diff(a frameValue, b frameValue, path string) {
+	if differ.err != nil {
+		return
+	}
+	switch a.kind() {
+	case frameKindScalar:
+		// compare the bits, so that NaN is equal to itself
+		if !bytes.Equal(a.bytes(), b.bytes()) {
+			differ.report(path, fmt.Sprint(a.scalar()), fmt.Sprint(b.scalar()))
+		}
+	case frameKindString:
+		strA, _, err := a.string()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		strB, _, err := b.string()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		if strA != strB {
+			differ.report(path, fmt.Sprintf("%q", strA), fmt.Sprintf("%q", strB))
+		}
+	case frameKindStruct:
+		for _, field := range a.fields() {
+			differ.diff(a.field(field), b.field(field), joinFieldPath(path, field.name))
+		}
+	case frameKindArray:
+		differ.elements(a.array(), b.array(), path)
+	case frameKindSlice:
+		elementsA, isNilA, err := a.slice()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		elementsB, isNilB, err := b.slice()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		if elementsA.length != elementsB.length {
+			differ.report(path, fmt.Sprintf("len %d", elementsA.length), fmt.Sprintf("len %d", elementsB.length))
+		} else if isNilA && !isNilB {
+			differ.report(path, "nil", "empty")
+		} else if !isNilA && isNilB {
+			differ.report(path, "empty", "nil")
+		}
+		differ.elements(elementsA, elementsB, path)
+	case frameKindPointer:
+		elemA, isNilA, err := a.deref()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		elemB, isNilB, err := b.deref()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		switch {
+		case isNilA && isNilB:
+		case isNilA:
+			differ.report(path, "nil", "non-nil")
+		case isNilB:
+			differ.report(path, "non-nil", "nil")
+		default:
+			differ.diff(elemA, elemB, path)
+		}
+	case frameKindTime:
+		timeA, err := a.time()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		timeB, err := b.time()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		if timeA != timeB {
+			differ.report(path, timeA.toTime().String(), timeB.toTime().String())
+		}
+	case frameKindBlob:
+		blobA, _, err := a.blob()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		blobB, _, err := b.blob()
+		if err != nil {
+			differ.err = err
+			return
+		}
+		if !bytes.Equal(blobA, blobB) {
+			differ.report(path, fmt.Sprintf("blob %x", blobA), fmt.Sprintf("blob %x", blobB))
+		}
+	default:
+		differ.err = customCodecError(path, a.valType)
+	}
+}
+
+// elements compares the elements both have
+func (differ *frameDiffer) elements(elementsA frameElements, elementsB frameElements, path string) {
+	for i := 0; i < elementsA.length && i < elementsB.length; i++ {
+		differ.diff(elementsA.at(i), elementsB.at(i), fmt.Sprintf("%s[%d]", path, i))
+	}
+}
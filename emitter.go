@@ -0,0 +1,447 @@
+package gocodec
+
+import (
+	"io"
+	"net"
+	"unsafe"
+)
+
+// frameEmitter writes the frame of a value without building it in memory.
+// It goes through the encoder tree twice, the layout pass collects the out of line values
+// in the depth first order Stream appends them, then the values are written with their positions known.
+// Every value is copied aside and rewritten by its encoder as Stream does in place,
+// the encoder registered with Config.RegisterCodec is run by a Stream aside, along with its out of line bytes.
+type frameEmitter struct {
+	cfg    *frozenConfig
+	writer *vectorWriter
+	// every out of line value in depth first order
+	blocks []emitterBlock
+	// every pointer word in the order Stream writes them, only collected with relocation table
+	relocations []emitterWord
+	// the values encoded by Stream aside during the layout pass
+	asides map[emitterWord]*emitterAside
+	// out of line values referenced by the blocks being written
+	children []emitterChild
+	// position right after the root value
+	rootEnd uintptr
+	// position of the next byte to write, relative to the frame start
+	pos uintptr
+	// the copy of the value being written, rewritten by the encoders as Stream.buf
+	self []byte
+	// where self[0] is in the frame
+	selfAt emitterWord
+	// index of the next out of line value to be referenced
+	next int
+	err  error
+}
+
+// valEmitter is implemented by the built-in encoders, so that frameEmitter does not need to encode them aside
+type valEmitter interface {
+	// layout adds the out of line values referenced by the value at ptr, which is located at the given word
+	layout(ptr unsafe.Pointer, emitter *frameEmitter, at emitterWord)
+	// emit rewrites the copy of the value at emitter.self[at:], and references its out of line values
+	emit(ptr unsafe.Pointer, emitter *frameEmitter, at uintptr)
+}
+
+type emitterBlock struct {
+	pos         uintptr
+	size        uintptr
+	align       uintptr
+	descendants int
+	// bytes to write as they are, instead of values
+	data []byte
+}
+
+// emitterWord locates a value by the out of line value it is in, block -1 is the root value
+type emitterWord struct {
+	block  int
+	offset uintptr
+}
+
+// emitterChild is the out of line value referenced by the block being written
+type emitterChild struct {
+	index int
+	// the values to write, encoded by encoder, or written as they are if encoder is nil
+	ptr      unsafe.Pointer
+	encoder  ValEncoder
+	elemSize uintptr
+	length   int
+}
+
+// emitterAside is the value encoded by Stream aside, followed by the out of line bytes at dataStart
+type emitterAside struct {
+	buf       []byte
+	dataStart uintptr
+	// the positions within the value of the words written by Stream.WriteOutOfLine
+	words []uintptr
+	// index of the block holding the out of line bytes, -1 if there is none
+	block int
+}
+
+func newFrameEmitter(cfg *frozenConfig, writer io.Writer) *frameEmitter {
+	return &frameEmitter{cfg: cfg, writer: newVectorWriter(writer), asides: map[emitterWord]*emitterAside{}}
+}
+
+// emitRoot writes one frame of the root value at ptr
+func (emitter *frameEmitter) emitRoot(encoder ValEncoder, ptr unsafe.Pointer, signature uint32) error {
+	emitter.blocks = emitter.blocks[:0]
+	emitter.relocations = emitter.relocations[:0]
+	for at := range emitter.asides {
+		delete(emitter.asides, at)
+	}
+	emitter.err = nil
+	emitter.layoutValue(encoder, ptr, emitterWord{block: -1})
+	if emitter.err != nil {
+		return emitter.err
+	}
+	valSize := encoder.Type().Size()
+	headerSize := headerSizeOf(emitter.cfg.relocationTable)
+	size := headerSize + valSize
+	emitter.rootEnd = size
+	for i := range emitter.blocks {
+		emitter.blocks[i].pos = alignUp(size, emitter.blocks[i].align)
+		size = emitter.blocks[i].pos + emitter.blocks[i].size
+	}
+	tableSize := uintptr(0)
+	if emitter.cfg.relocationTable {
+		tableSize = uintptr(4*len(emitter.relocations) + 4)
+	}
+	size = alignUp(size+tableSize, frameAlign)
+	if uint64(size) > frameMaxSize {
+		return errFrameTooLarge
+	}
+	header := [4]uint32{uint32(size), signature}
+	if emitter.cfg.relocationTable {
+		header = [4]uint32{frameExtendedMark, signature, uint32(size), frameFlagRelocationTable}
+	}
+	emitter.writer.Write(ptrAsBytes(int(headerSize), unsafe.Pointer(&header)))
+	emitter.pos = headerSize
+	emitter.block(emitterChild{index: -1, ptr: ptr, encoder: encoder, elemSize: valSize, length: 1})
+	if emitter.err != nil {
+		return emitter.err
+	}
+	emitter.zeros(size - tableSize - emitter.pos)
+	if emitter.cfg.relocationTable {
+		emitter.relocationTableOf()
+	}
+	return emitter.writer.Flush()
+}
+
+// release drops the references to the last value written, so that the emitter can be kept for reuse
+func (emitter *frameEmitter) release() {
+	blocks := emitter.blocks[:cap(emitter.blocks)]
+	for i := range blocks {
+		blocks[i] = emitterBlock{}
+	}
+	children := emitter.children[:cap(emitter.children)]
+	for i := range children {
+		children[i] = emitterChild{}
+	}
+	for at := range emitter.asides {
+		delete(emitter.asides, at)
+	}
+	emitter.blocks = emitter.blocks[:0]
+	emitter.children = emitter.children[:0]
+	emitter.writer.reset(nil)
+}
+
+// relocationTableOf writes the offsets of pointer words relative to the root value, then the count
+func (emitter *frameEmitter) relocationTableOf() {
+	for _, relocation := range emitter.relocations {
+		pos := frameExtendedHeaderSize + relocation.offset
+		if relocation.block >= 0 {
+			pos = emitter.blocks[relocation.block].pos + relocation.offset
+		}
+		offset := uint32(pos - frameExtendedHeaderSize)
+		emitter.bytes(ptrAsBytes(4, unsafe.Pointer(&offset)))
+	}
+	count := uint32(len(emitter.relocations))
+	emitter.bytes(ptrAsBytes(4, unsafe.Pointer(&count)))
+}
+
+func (emitter *frameEmitter) relocate(at emitterWord) {
+	if emitter.cfg.relocationTable {
+		emitter.relocations = append(emitter.relocations, at)
+	}
+}
+
+func (emitter *frameEmitter) layoutValue(encoder ValEncoder, ptr unsafe.Pointer, at emitterWord) {
+	if emitter.err != nil {
+		return
+	}
+	if valEmitter, isValEmitter := encoder.(valEmitter); isValEmitter {
+		valEmitter.layout(ptr, emitter, at)
+		return
+	}
+	emitter.layoutAside(encoder, ptr, at)
+}
+
+// addBlock adds the out of line value, its descendants are the blocks added until endBlock
+func (emitter *frameEmitter) addBlock(block emitterBlock) int {
+	emitter.blocks = append(emitter.blocks, block)
+	return len(emitter.blocks) - 1
+}
+
+func (emitter *frameEmitter) endBlock(index int) {
+	emitter.blocks[index].descendants = len(emitter.blocks) - index - 1
+}
+
+// layoutValues adds the out of line value of length values, referenced by the pointer word at the given location
+func (emitter *frameEmitter) layoutValues(encoder ValEncoder, ptr unsafe.Pointer, elemSize uintptr, elemAlign uintptr,
+	length int, at emitterWord) {
+	emitter.relocate(at)
+	index := emitter.addBlock(emitterBlock{size: uintptr(length) * elemSize, align: elemAlign})
+	if encoder != nil {
+		for i := 0; i < length; i++ {
+			emitter.layoutValue(encoder, unsafe.Pointer(uintptr(ptr)+uintptr(i)*elemSize),
+				emitterWord{index, uintptr(i) * elemSize})
+		}
+	}
+	emitter.endBlock(index)
+}
+
+// layoutAside encodes the value by Stream, the copy is followed by zeros up to the frame alignment,
+// so that Stream.WriteOutOfLine pads the out of line bytes the same as in the frame
+func (emitter *frameEmitter) layoutAside(encoder ValEncoder, ptr unsafe.Pointer, at emitterWord) {
+	size := encoder.Type().Size()
+	dataStart := alignUp(size, frameAlign)
+	buf := make([]byte, dataStart)
+	copy(buf, ptrAsBytes(int(size), ptr))
+	stream := emitter.cfg.NewStream(buf)
+	stream.tracksRelocations = true
+	encoder.Encode(ptr, stream)
+	if stream.Error != nil {
+		emitter.err = stream.Error
+		return
+	}
+	aside := &emitterAside{buf: stream.buf, dataStart: dataStart, words: stream.relocations, block: -1}
+	for _, word := range aside.words {
+		emitter.relocate(emitterWord{at.block, at.offset + word})
+	}
+	if uintptr(len(stream.buf)) > dataStart {
+		aside.block = emitter.addBlock(emitterBlock{
+			size: uintptr(len(stream.buf)) - dataStart, align: frameAlign, data: stream.buf[dataStart:]})
+	}
+	emitter.asides[at] = aside
+}
+
+func (emitter *frameEmitter) emitValue(encoder ValEncoder, ptr unsafe.Pointer, at uintptr) {
+	if valEmitter, isValEmitter := encoder.(valEmitter); isValEmitter {
+		valEmitter.emit(ptr, emitter, at)
+		return
+	}
+	emitter.emitAside(at)
+}
+
+// emitAside copies the value encoded aside, with the relative offsets moved to where the out of line bytes are
+func (emitter *frameEmitter) emitAside(at uintptr) {
+	aside := emitter.asides[emitterWord{emitter.selfAt.block, emitter.selfAt.offset + at}]
+	copy(emitter.self[at:], aside.buf[:aside.dataStart])
+	if aside.block < 0 {
+		return
+	}
+	index := emitter.reference(aside.block)
+	for _, word := range aside.words {
+		pWord := unsafe.Pointer(&emitter.self[at+word])
+		target := word + *(*uintptr)(pWord) - aside.dataStart
+		*(*uintptr)(pWord) = emitter.blocks[index].pos + target - (emitter.pos + at + word)
+	}
+	emitter.children = append(emitter.children, emitterChild{index: index})
+}
+
+// reference takes the next out of line value, which must be the expected one
+func (emitter *frameEmitter) reference(expected int) int {
+	index := emitter.next
+	if index != expected || index >= len(emitter.blocks) {
+		panic("out of line values are not referenced in the order of layout")
+	}
+	emitter.next += 1 + emitter.blocks[index].descendants
+	return index
+}
+
+// relOffsetAt writes the relative offset to the next out of line value into the word at self[at:], and returns its index
+func (emitter *frameEmitter) relOffsetAt(at uintptr) int {
+	index := emitter.reference(emitter.next)
+	emitter.setWord(at, emitter.blocks[index].pos-(emitter.pos+at))
+	return index
+}
+
+func (emitter *frameEmitter) setWord(at uintptr, word uintptr) {
+	*(*uintptr)(unsafe.Pointer(&emitter.self[at])) = word
+}
+
+// emitValues references length values at ptr by the word at self[at:], they are written after the current block
+func (emitter *frameEmitter) emitValues(encoder ValEncoder, ptr unsafe.Pointer, elemSize uintptr, length int, at uintptr) {
+	index := emitter.relOffsetAt(at)
+	emitter.children = append(emitter.children, emitterChild{
+		index: index, ptr: ptr, encoder: encoder, elemSize: elemSize, length: length})
+}
+
+// emitData references the bytes laid out as block by the word at self[at:]
+func (emitter *frameEmitter) emitData(at uintptr) {
+	index := emitter.relOffsetAt(at)
+	emitter.children = append(emitter.children, emitterChild{index: index})
+}
+
+// block writes the values of the child, then the out of line values they reference
+func (emitter *frameEmitter) block(child emitterChild) {
+	emitter.next = child.index + 1
+	if child.encoder == nil || encoderIsRaw(child.encoder) {
+		// written as they are, such as the bytes of string or the elements of []uint64
+		emitter.bytes(ptrAsBytes(child.length*int(child.elemSize), child.ptr))
+		return
+	}
+	start := len(emitter.children)
+	for i := 0; i < child.length; i++ {
+		elemPtr := unsafe.Pointer(uintptr(child.ptr) + uintptr(i)*child.elemSize)
+		emitter.self = append(emitter.self[:0], ptrAsBytes(int(child.elemSize), elemPtr)...)
+		emitter.selfAt = emitterWord{child.index, uintptr(i) * child.elemSize}
+		emitter.emitValue(child.encoder, elemPtr, 0)
+		emitter.bytes(emitter.self)
+		if len(emitter.self) >= vectorDirectSize {
+			// kept by the writer until flushed
+			emitter.self = nil
+		}
+	}
+	end := len(emitter.children)
+	for i := start; i < end; i++ {
+		grandChild := emitter.children[i]
+		block := emitter.blocks[grandChild.index]
+		emitter.zeros(block.pos - emitter.pos)
+		if block.data != nil {
+			emitter.bytes(block.data)
+			continue
+		}
+		emitter.block(grandChild)
+	}
+	emitter.children = emitter.children[:start]
+}
+
+// positionOf tells where Stream appends at the moment the out of line value of index is about to be added,
+// that is right after the previous one, before the padding for the alignment
+func (emitter *frameEmitter) positionOf(index int) uintptr {
+	if index == 0 {
+		return emitter.rootEnd
+	}
+	previous := emitter.blocks[index-1]
+	return previous.pos + previous.size
+}
+
+func (emitter *frameEmitter) bytes(data []byte) {
+	emitter.writer.Write(data)
+	emitter.pos += uintptr(len(data))
+}
+
+var zeroBytes [64]byte
+
+func (emitter *frameEmitter) zeros(size uintptr) {
+	for size > 0 {
+		chunk := size
+		if chunk > uintptr(len(zeroBytes)) {
+			chunk = uintptr(len(zeroBytes))
+		}
+		emitter.bytes(zeroBytes[:chunk])
+		size -= chunk
+	}
+}
+
+// encoderIsRaw tells if the values are written as they are, padding included
+func encoderIsRaw(encoder ValEncoder) bool {
+	switch encoder := encoder.(type) {
+	case *NoopCodec:
+		return true
+	case *arrayEncoder:
+		return encoder.elemEncoder == nil || encoderIsRaw(encoder.elemEncoder)
+	case *structEncoder:
+		if len(encoder.gaps) > 0 {
+			return false
+		}
+		for _, field := range encoder.fields {
+			if !encoderIsRaw(field.encoder) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+const (
+	vectorChunkSize = 64 * 1024
+	// regions at least this large are handed to the writer without being copied
+	vectorDirectSize = 16 * 1024
+	vectorMaxPending = 1024
+)
+
+// vectorWriter gathers small writes into chunks, and passes large regions through,
+// so that net.Buffers can write them with writev when the writer is a connection
+type vectorWriter struct {
+	writer     io.Writer
+	chunk      []byte
+	chunkStart int
+	pending    net.Buffers
+	err        error
+	// how many times the bytes have been passed to the writer
+	flushes int
+}
+
+func newVectorWriter(writer io.Writer) *vectorWriter {
+	return &vectorWriter{writer: writer, chunk: make([]byte, 0, vectorChunkSize)}
+}
+
+// reset drops what is not flushed, and writes to writer from now on
+func (writer *vectorWriter) reset(w io.Writer) {
+	writer.discard()
+	writer.writer = w
+	writer.err = nil
+}
+
+// discard drops what is not flushed, the error of the writer is kept
+func (writer *vectorWriter) discard() {
+	for i := range writer.pending {
+		writer.pending[i] = nil
+	}
+	writer.pending = writer.pending[:0]
+	writer.chunk = writer.chunk[:0]
+	writer.chunkStart = 0
+}
+
+func (writer *vectorWriter) Write(data []byte) {
+	if writer.err != nil {
+		return
+	}
+	if len(data) >= vectorDirectSize {
+		writer.cut()
+		writer.pending = append(writer.pending, data)
+		if len(writer.pending) >= vectorMaxPending {
+			writer.Flush()
+		}
+		return
+	}
+	if len(writer.chunk)+len(data) > cap(writer.chunk) {
+		writer.Flush()
+	}
+	writer.chunk = append(writer.chunk, data...)
+}
+
+// cut moves the bytes copied into the chunk so far to pending, keeping the order of writes
+func (writer *vectorWriter) cut() {
+	if len(writer.chunk) > writer.chunkStart {
+		writer.pending = append(writer.pending, writer.chunk[writer.chunkStart:])
+		writer.chunkStart = len(writer.chunk)
+	}
+}
+
+func (writer *vectorWriter) Flush() error {
+	if writer.err != nil {
+		return writer.err
+	}
+	writer.cut()
+	if len(writer.pending) > 0 {
+		writer.flushes++
+	}
+	buffers := writer.pending
+	_, writer.err = buffers.WriteTo(writer.writer)
+	writer.discard()
+	return writer.err
+}
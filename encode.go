@@ -1,9 +1,11 @@
 package gocodec
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"unsafe"
+	"github.com/v2pro/plz/countlog"
 )
 
 type Stream struct {
@@ -13,42 +15,252 @@ type Stream struct {
 	// buf + len(buf) => the output of encoder
 	buf    []byte
 	cursor uintptr
-	Error  error
+	// buf position of the frame being written, out of line values are aligned relative to it
+	frameStart int
+	// buf position of every pointer word written, only tracked when relocation table is enabled,
+	// or the value is encoded aside by frameEmitter
+	relocations       []uintptr
+	tracksRelocations bool
+	Error             error
 }
 
 func (cfg *frozenConfig) NewStream(buf []byte) *Stream {
 	return &Stream{cfg: cfg, buf: buf}
 }
 
+// Reset starts over with the buffer, the error of previous Marshal is cleared as well
 func (stream *Stream) Reset(buf []byte) {
 	stream.buf = buf
 	stream.cursor = 0
+	stream.frameStart = 0
+	stream.Error = nil
 }
 
-func (stream *Stream) Marshal(val interface{}) uint32 {
-	valType := reflect.TypeOf(val)
+// Marshal appends one frame to the buffer, and returns its size.
+// On error nothing is appended, 0 is returned and the error is kept in stream.Error,
+// later Marshal is a no-op until the stream is reset or rolled back.
+func (stream *Stream) Marshal(val interface{}) (size uint32) {
+	return stream.marshal(reflect.TypeOf(val), ptrOfEmptyInterface(val), false)
+}
+
+// MarshalPointer appends the same frame as Marshal(*ptr), without copying the value into interface{} first,
+// which matters for large struct. ptr must not be nil.
+func (stream *Stream) MarshalPointer(ptr interface{}) (size uint32) {
+	if stream.Error != nil {
+		return 0
+	}
+	ptrType := reflect.TypeOf(ptr)
+	if ptrType == nil || ptrType.Kind() != reflect.Ptr {
+		stream.ReportError("MarshalPointer", errors.New("ptr must be a pointer"))
+		return 0
+	}
+	valPtr := ptrOfEmptyInterface(ptr)
+	if valPtr == nil {
+		stream.ReportError("MarshalPointer", errors.New("ptr must not be nil"))
+		return 0
+	}
+	return stream.marshal(ptrType.Elem(), valPtr, true)
+}
+
+// marshal encodes the value at ptr, which is the word of interface{} holding the value, unless byPointer
+func (stream *Stream) marshal(valType reflect.Type, ptr unsafe.Pointer, byPointer bool) (size uint32) {
+	if stream.Error != nil {
+		return 0
+	}
+	baseCursor := len(stream.buf)
+	var encoder RootEncoder
+	defer func() {
+		recovered := recover()
+		if recovered != nil {
+			countlog.Fatal("event!gocodec.failed to marshal",
+				"err", recovered,
+				"stacktrace", countlog.ProvideStacktrace)
+			stream.ReportError("Marshal", fmt.Errorf("%v", locatePanic(stream.cfg, encoder, ptr, recovered)))
+		}
+		if stream.Error != nil {
+			stream.buf = stream.buf[:baseCursor]
+			size = 0
+		}
+	}()
 	encoder, err := encoderOfType(stream.cfg, valType)
 	if err != nil {
 		stream.ReportError("EncodeVal", err)
 		return 0
 	}
-	baseCursor := len(stream.buf)
-	stream.buf = append(stream.buf, []byte{
-		0, 0, 0, 0, // size
-		0, 0, 0, 0, // signature
-	}...)
-	encoder.EncodeEmptyInterface(ptrOfEmptyInterface(val), stream)
+	if _, isDirect := encoder.(*singlePointerFix); isDirect && byPointer {
+		// interface{} would hold the value itself, as it is a single pointer
+		ptr = *(*unsafe.Pointer)(ptr)
+	}
+	stream.beginFrame()
+	encoder.EncodeEmptyInterface(ptr, stream)
 	if stream.Error != nil {
 		return 0
 	}
-	pSize := unsafe.Pointer(&stream.buf[baseCursor])
-	size := uint32(len(stream.buf) - baseCursor)
-	*(*uint32)(pSize) = size
-	pSig := unsafe.Pointer(&stream.buf[baseCursor+4])
-	*(*uint32)(pSig) = encoder.Signature()
+	return stream.endFrame(encoder.Signature())
+}
+
+// beginFrame appends the frame header, which is filled by endFrame after the value is written
+func (stream *Stream) beginFrame() {
+	stream.frameStart = len(stream.buf)
+	stream.zeros(headerSizeOf(stream.cfg.relocationTable))
+	stream.relocations = stream.relocations[:0]
+}
+
+// endFrame appends the relocation table or the padding, then fills the frame header
+func (stream *Stream) endFrame(signature uint32) (size uint32) {
+	baseCursor := stream.frameStart
+	if stream.cfg.relocationTable {
+		stream.writeRelocationTable(uintptr(baseCursor + frameExtendedHeaderSize))
+	} else {
+		stream.align(frameAlign)
+	}
+	if uint64(len(stream.buf)-baseCursor) > frameMaxSize {
+		stream.ReportError("EncodeVal", errFrameTooLarge)
+		return 0
+	}
+	size = uint32(len(stream.buf) - baseCursor)
+	if stream.cfg.relocationTable {
+		header := (*[4]uint32)(unsafe.Pointer(&stream.buf[baseCursor]))
+		*header = [4]uint32{frameExtendedMark, signature, size, frameFlagRelocationTable}
+	} else {
+		header := (*[2]uint32)(unsafe.Pointer(&stream.buf[baseCursor]))
+		*header = [2]uint32{size, signature}
+	}
 	return size
 }
 
+// locatePanic walks the value again after encoding it panicked, to tell the path of the member it panicked at.
+// Every member is encoded aside, and the first one panicking again is the one to look into,
+// so that Encode does not need to keep track of where it is.
+func locatePanic(cfg *frozenConfig, encoder RootEncoder, ptr unsafe.Pointer, recovered interface{}) interface{} {
+	var root *rootEncoder
+	switch encoder := encoder.(type) {
+	case *rootEncoder:
+		root = encoder
+	case *singlePointerFix:
+		root = &encoder.rootEncoder
+		word := ptr
+		ptr = unsafe.Pointer(&word)
+	default:
+		return recovered
+	}
+	path := panicPathOf(cfg, root.encoder, ptr)
+	if path == "" {
+		return recovered
+	}
+	return &pathError{path: path, err: recovered}
+}
+
+func panicPathOf(cfg *frozenConfig, encoder ValEncoder, ptr unsafe.Pointer) string {
+	switch encoder := encoder.(type) {
+	case *structEncoder:
+		for _, field := range encoder.fields {
+			fieldPtr := unsafe.Pointer(uintptr(ptr) + field.offset)
+			if encodePanics(cfg, field.encoder, fieldPtr) {
+				return "." + field.name + panicPathOf(cfg, field.encoder, fieldPtr)
+			}
+		}
+	case *arrayEncoder:
+		for i := 0; encoder.elemEncoder != nil && i < encoder.arrayLength; i++ {
+			elemPtr := unsafe.Pointer(uintptr(ptr) + uintptr(i)*encoder.elementSize)
+			if encodePanics(cfg, encoder.elemEncoder, elemPtr) {
+				return fmt.Sprintf("[%d]", i) + panicPathOf(cfg, encoder.elemEncoder, elemPtr)
+			}
+		}
+	case *sliceEncoder:
+		header := (*sliceReadonlyHeader)(ptr)
+		for i := 0; encoder.elemEncoder != nil && i < header.Len; i++ {
+			elemPtr := unsafe.Pointer(uintptr(header.Data) + uintptr(i*encoder.elemSize))
+			if encodePanics(cfg, encoder.elemEncoder, elemPtr) {
+				return fmt.Sprintf("[%d]", i) + panicPathOf(cfg, encoder.elemEncoder, elemPtr)
+			}
+		}
+	case *pointerEncoder:
+		return panicPathOf(cfg, encoder.elemEncoder, *(*unsafe.Pointer)(ptr))
+	}
+	return ""
+}
+
+func encodePanics(cfg *frozenConfig, encoder ValEncoder, ptr unsafe.Pointer) (panicked bool) {
+	defer func() {
+		if recover() != nil {
+			panicked = true
+		}
+	}()
+	stream := cfg.NewStream(append([]byte(nil), ptrAsBytes(int(encoder.Type().Size()), ptr)...))
+	encoder.Encode(ptr, stream)
+	return false
+}
+
+// Checkpoint marks the current end of the buffer, frames marshaled after it can be dropped by Rollback.
+func (stream *Stream) Checkpoint() int {
+	return len(stream.buf)
+}
+
+// Rollback truncates the buffer back to the checkpoint and clears the error,
+// so that several frames can be written as a whole or not at all.
+// The checkpoint out of the buffer is reported as error, and the buffer is left as it is.
+func (stream *Stream) Rollback(checkpoint int) {
+	if checkpoint < 0 || checkpoint > len(stream.buf) {
+		stream.ReportError("Rollback", fmt.Errorf("checkpoint %d is out of the buffer of %d bytes",
+			checkpoint, len(stream.buf)))
+		return
+	}
+	stream.buf = stream.buf[:checkpoint]
+	stream.Error = nil
+}
+
+// align pads the buffer with zeros, so that the next out of line value starts at the alignment
+func (stream *Stream) align(alignment uintptr) {
+	pos := uintptr(len(stream.buf) - stream.frameStart)
+	stream.zeros(alignUp(pos, alignment) - pos)
+}
+
+func (stream *Stream) zeros(count uintptr) {
+	for ; count > 0; count-- {
+		stream.buf = append(stream.buf, 0)
+	}
+}
+
+// writeRelOffset replaces the pointer word at cursor with the offset to the end of buf,
+// where the out of line value is going to be appended
+func (stream *Stream) writeRelOffset() {
+	pWord := unsafe.Pointer(&stream.buf[stream.cursor])
+	*(*uintptr)(pWord) = uintptr(len(stream.buf)) - stream.cursor
+	if stream.cfg.relocationTable || stream.tracksRelocations {
+		stream.relocations = append(stream.relocations, stream.cursor)
+	}
+}
+
+// WriteWord replaces the word at offset within the value being encoded,
+// it is meant for the codec registered with Config.RegisterCodec
+func (stream *Stream) WriteWord(offset uintptr, word uintptr) {
+	*(*uintptr)(unsafe.Pointer(&stream.buf[stream.cursor+offset])) = word
+}
+
+// WriteOutOfLine appends data after the frame written so far, aligned to 8 bytes,
+// and replaces the word at offset within the value being encoded with the relative offset to it
+func (stream *Stream) WriteOutOfLine(offset uintptr, data []byte) {
+	cursor := stream.cursor
+	stream.cursor += offset
+	stream.align(frameAlign)
+	stream.writeRelOffset()
+	stream.cursor = cursor
+	stream.buf = append(stream.buf, data...)
+}
+
+// writeRelocationTable pads the frame, so that it still ends at the alignment with the table appended
+func (stream *Stream) writeRelocationTable(rootCursor uintptr) {
+	end := uintptr(len(stream.buf)-stream.frameStart) + uintptr(4*len(stream.relocations)+4)
+	stream.zeros(alignUp(end, frameAlign) - end)
+	for _, relocation := range stream.relocations {
+		offset := uint32(relocation - rootCursor)
+		stream.buf = append(stream.buf, ptrAsBytes(4, unsafe.Pointer(&offset))...)
+	}
+	count := uint32(len(stream.relocations))
+	stream.buf = append(stream.buf, ptrAsBytes(4, unsafe.Pointer(&count))...)
+}
+
 func (stream *Stream) Buffer() []byte {
 	return stream.buf
 }
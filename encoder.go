@@ -0,0 +1,87 @@
+package gocodec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"unsafe"
+	"github.com/v2pro/plz/countlog"
+)
+
+// Encoder writes frames to io.Writer, without building them in memory.
+// The layout of the value is worked out by the same encoders as Stream, then the root and the out of line values
+// are written in the order Stream appends them, large regions such as []byte or []uint64 are passed to the writer
+// as they are. The bytes written are identical to Stream.Marshal of the same value.
+type Encoder struct {
+	cfg     *frozenConfig
+	emitter *frameEmitter
+	// set once a frame failed after part of it has reached the writer, nothing can be written after it
+	err error
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return DefaultConfig.NewEncoder(w)
+}
+
+func (cfg *frozenConfig) NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{cfg: cfg, emitter: newFrameEmitter(cfg, w)}
+}
+
+// Encode writes one frame of val
+func (encoder *Encoder) Encode(val interface{}) error {
+	return encoder.encode(reflect.TypeOf(val), ptrOfEmptyInterface(val), false)
+}
+
+// EncodePointer writes the same frame as Encode(*ptr), without copying the value into interface{} first.
+// ptr must not be nil.
+func (encoder *Encoder) EncodePointer(ptr interface{}) error {
+	ptrType := reflect.TypeOf(ptr)
+	if ptrType == nil || ptrType.Kind() != reflect.Ptr {
+		return errors.New("EncodePointer: ptr must be a pointer")
+	}
+	valPtr := ptrOfEmptyInterface(ptr)
+	if valPtr == nil {
+		return errors.New("EncodePointer: ptr must not be nil")
+	}
+	return encoder.encode(ptrType.Elem(), valPtr, true)
+}
+
+// encode writes the value at ptr, which is the word of interface{} holding the value, unless byPointer.
+// If it fails, what is written of the frame is dropped, unless some has already reached the writer,
+// then the Encoder is broken and every later call fails.
+func (encoder *Encoder) encode(valType reflect.Type, ptr unsafe.Pointer, byPointer bool) (err error) {
+	if encoder.err != nil {
+		return encoder.err
+	}
+	rootEncoder, err := encoderOfType(encoder.cfg, valType)
+	if err != nil {
+		return fmt.Errorf("Encode: %s", err)
+	}
+	if _, isDirect := rootEncoder.(*singlePointerFix); isDirect && byPointer {
+		// interface{} would hold the value itself, as it is a single pointer
+		ptr = *(*unsafe.Pointer)(ptr)
+	}
+	writer := encoder.emitter.writer
+	flushes := writer.flushes
+	defer func() {
+		recovered := recover()
+		if recovered != nil {
+			countlog.Fatal("event!gocodec.failed to encode",
+				"err", recovered,
+				"stacktrace", countlog.ProvideStacktrace)
+			err = fmt.Errorf("Encode: %v", locatePanic(encoder.cfg, rootEncoder, ptr, recovered))
+		}
+		if err == nil {
+			return
+		}
+		if writer.flushes != flushes {
+			encoder.err = fmt.Errorf("Encode: frame is partially written by the failure: %s", err)
+		}
+		writer.discard()
+	}()
+	if err := rootEncoder.emitEmptyInterface(ptr, encoder.emitter); err != nil {
+		return fmt.Errorf("Encode: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package gocodec
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// FieldHandle locates a fixed size field within the root value of a frame,
+// so that it can be read or patched in place without decoding the frame.
+type FieldHandle struct {
+	signature uint32
+	path      string
+	offset    uintptr
+	fieldType reflect.Type
+}
+
+func CompileField(candidatePointer interface{}, path string) (*FieldHandle, error) {
+	return DefaultConfig.CompileField(candidatePointer, path)
+}
+
+func SetField(buf []byte, candidatePointer interface{}, path string, val interface{}) error {
+	return DefaultConfig.SetField(buf, candidatePointer, path, val)
+}
+
+// CompileField resolves path like "Field1.Field2[3]" against the type candidatePointer points to.
+// Only struct fields and array elements are followed, pointers and slices live out of line.
+func (cfg *frozenConfig) CompileField(candidatePointer interface{}, path string) (*FieldHandle, error) {
+	valType := reflect.TypeOf(candidatePointer).Elem()
+	decoder, err := decoderOfType(cfg, valType)
+	if err != nil {
+		return nil, err
+	}
+	offset := uintptr(0)
+	fieldType := valType
+	for _, segment := range strings.Split(path, ".") {
+		name := segment
+		var indices []string
+		if bracket := strings.IndexByte(segment, '['); bracket != -1 {
+			name = segment[:bracket]
+			indices = strings.Split(strings.TrimSuffix(segment[bracket+1:], "]"), "][")
+		}
+		if name != "" {
+			if fieldType.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("%s: %s is not struct", path, fieldType.String())
+			}
+			field, found := fieldType.FieldByName(name)
+			if !found || len(field.Index) != 1 {
+				return nil, fmt.Errorf("%s: %s has no field %s", path, fieldType.String(), name)
+			}
+			// the skipped field is always zero in the frame, setting it would be lost when decoding
+			if tag, _ := fieldTagOf(field); tag == fieldTagSkip {
+				return nil, fmt.Errorf("%s: %s.%s is tagged gocodec:\"-\", it is not encoded",
+					path, fieldType.String(), name)
+			}
+			offset += field.Offset
+			fieldType = field.Type
+		}
+		for _, index := range indices {
+			if fieldType.Kind() != reflect.Array {
+				return nil, fmt.Errorf("%s: %s is not array", path, fieldType.String())
+			}
+			i, err := strconv.Atoi(index)
+			if err != nil || i < 0 || i >= fieldType.Len() {
+				return nil, fmt.Errorf("%s: invalid index %s of %s", path, index, fieldType.String())
+			}
+			offset += uintptr(i) * fieldType.Elem().Size()
+			fieldType = fieldType.Elem()
+		}
+	}
+	fieldDecoder, err := createDecoderOfType(cfg, fieldType, false)
+	if err != nil {
+		return nil, err
+	}
+	if fieldDecoder.HasPointer() {
+		return nil, fmt.Errorf("%s: %s is not fixed size", path, fieldType.String())
+	}
+	return &FieldHandle{
+		signature: decoder.Signature(),
+		path:      path,
+		offset:    offset,
+		fieldType: fieldType,
+	}, nil
+}
+
+func (cfg *frozenConfig) SetField(buf []byte, candidatePointer interface{}, path string, val interface{}) error {
+	handle, err := cfg.CompileField(candidatePointer, path)
+	if err != nil {
+		return err
+	}
+	return handle.Set(buf, val)
+}
+
+func (handle *FieldHandle) Type() reflect.Type {
+	return handle.fieldType
+}
+
+// Set overwrites the field of the frame at the start of buf, buf can be a writable mmap
+func (handle *FieldHandle) Set(buf []byte, val interface{}) error {
+	field, err := handle.locate(buf)
+	if err != nil {
+		return err
+	}
+	valType := reflect.TypeOf(val)
+	if valType != handle.fieldType {
+		return fmt.Errorf("%s: expect %s, but found %v", handle.path, handle.fieldType.String(), valType)
+	}
+	copy(field, ptrAsBytes(len(field), ptrOfEmptyInterface(val)))
+	return nil
+}
+
+// Get returns a copy of the field of the frame at the start of buf
+func (handle *FieldHandle) Get(buf []byte) (interface{}, error) {
+	field, err := handle.locate(buf)
+	if err != nil {
+		return nil, err
+	}
+	if len(field) == 0 {
+		return reflect.Zero(handle.fieldType).Interface(), nil
+	}
+	return reflect.NewAt(handle.fieldType, unsafe.Pointer(&field[0])).Elem().Interface(), nil
+}
+
+func (handle *FieldHandle) locate(buf []byte) ([]byte, error) {
+	if err := checkFrame(buf); err != nil {
+		return nil, err
+	}
+	if !frameHolds(buf, handle.signature) {
+		return nil, errors.New("field does not match the signature")
+	}
+	start := frameHeaderSizeOf(buf) + handle.offset
+	end := start + handle.fieldType.Size()
+	if end > uintptr(frameSize(buf)) {
+		return nil, errors.New("field is out of frame")
+	}
+	return buf[start:end], nil
+}
@@ -0,0 +1,278 @@
+package gocodec
+
+import (
+	"errors"
+	"sync/atomic"
+	"unsafe"
+)
+
+// a plain frame is laid out as
+// size (4 bytes) + signature (4 bytes) + root value + out of line values
+// the frame with relocation table has the extended header instead
+// mark (4 bytes) + signature (4 bytes) + size (4 bytes) + flags (4 bytes) + root value + out of line values
+// + relocation table
+// the size of plain frame is never less than its header, so the small mark tells the extended header apart.
+// The size covers the whole frame, the flags word holds the frame flags and the in place decoding state.
+// Every out of line value starts at the alignment of its type relative to the frame start,
+// and the frame is padded with zeros to a multiple of 8 bytes (before the relocation table if any),
+// so that the values decoded in place are aligned as long as the frame itself is.
+const (
+	frameHeaderSize                 = 8
+	frameExtendedHeaderSize         = 16
+	frameExtendedMark        uint32 = 1
+	frameMaxSize             uint64 = 1<<32 - 1
+	frameAlign                      = 8
+	frameFlagRelocationTable uint32 = 1
+	// frame decoded in place goes from portable to decoding then relocated,
+	// so that the relative offsets are never added twice.
+	// If decoding fails after some pointers have been written, the frame is broken for good.
+	frameStatePortable  uint32 = 0
+	frameStateDecoding  uint32 = 1
+	frameStateRelocated uint32 = 2
+	frameStateBroken    uint32 = 3
+	frameStateShift            = 30
+	frameStateMask      uint32 = 3 << frameStateShift
+)
+
+// plain header has no room for the state, the signature word is scrambled by the key of the state instead,
+// so that the portable frame keeps the signature as it is
+var frameStateKeys = [4]uint32{0, 0x6a09e667, 0xbb67ae85, 0x3c6ef372}
+
+// ErrFrameBusy is reported when the frame is being decoded in place by another goroutine at the same time.
+// It does not wait, the frame is ready to use once the other goroutine is done,
+// SharedView should be used to decode one frame from many goroutines.
+var ErrFrameBusy = errors.New("frame is being decoded in place")
+
+var errFrameTooLarge = errors.New("frame exceeds the maximum size")
+var errFrameFormat = errors.New("not a frame of known format, it might be written by a newer version")
+var errFrameTruncated = errors.New("truncated frame")
+var errNoRelocationTable = errors.New("frame has no relocation table")
+var errPointerOutOfFrame = errors.New("pointer does not point into the frame")
+var errFrameRelocated = errors.New("frame is already decoded in place")
+var errFrameNotRelocated = errors.New("frame is not decoded in place")
+var errFrameBroken = errors.New("frame is partially decoded in place by failed Unmarshal, it can not be used")
+var errSignatureMismatch = errors.New("no decoder matches the signature")
+
+// checkFrame validates the header of the frame at the start of buf
+func checkFrame(buf []byte) error {
+	if len(buf) < frameHeaderSize {
+		return errFrameTruncated
+	}
+	headerSize := uint32(frameHeaderSize)
+	if frameHasExtendedHeader(buf) {
+		if len(buf) < frameExtendedHeaderSize {
+			return errFrameTruncated
+		}
+		if frameFlags(buf)&^(frameStateMask|frameFlagRelocationTable) != 0 {
+			return errFrameFormat
+		}
+		headerSize = frameExtendedHeaderSize
+	}
+	size := frameSize(buf)
+	if size < headerSize {
+		return errFrameFormat
+	}
+	if uint64(size) > uint64(len(buf)) {
+		return errFrameTruncated
+	}
+	if frameHasRelocationTable(buf) {
+		if size < headerSize+4 {
+			return errFrameFormat
+		}
+		count := *(*uint32)(unsafe.Pointer(&buf[size-4]))
+		if uint64(count)*4+4 > uint64(size-headerSize) {
+			return errFrameFormat
+		}
+	}
+	return nil
+}
+
+// alignUp rounds pos up to the multiple of alignment, which is a power of 2
+func alignUp(pos uintptr, alignment uintptr) uintptr {
+	return (pos + alignment - 1) &^ (alignment - 1)
+}
+
+// headerSizeOf is the size of the header written by the config
+func headerSizeOf(relocationTable bool) uintptr {
+	if relocationTable {
+		return frameExtendedHeaderSize
+	}
+	return frameHeaderSize
+}
+
+func frameHasExtendedHeader(frame []byte) bool {
+	return *(*uint32)(unsafe.Pointer(&frame[0])) == frameExtendedMark
+}
+
+// frameHeaderSizeOf is where the root value starts
+func frameHeaderSizeOf(frame []byte) uintptr {
+	if frameHasExtendedHeader(frame) {
+		return frameExtendedHeaderSize
+	}
+	return frameHeaderSize
+}
+
+// frameSize is 0 if the extended header is truncated
+func frameSize(frame []byte) uint32 {
+	if !frameHasExtendedHeader(frame) {
+		return *(*uint32)(unsafe.Pointer(&frame[0]))
+	}
+	if len(frame) < frameExtendedHeaderSize {
+		return 0
+	}
+	return *(*uint32)(unsafe.Pointer(&frame[8]))
+}
+
+// frameSignature is the signature word as it is, which is scrambled if the plain frame is decoded in place
+func frameSignature(frame []byte) uint32 {
+	return atomic.LoadUint32((*uint32)(unsafe.Pointer(&frame[4])))
+}
+
+func frameFlags(frame []byte) uint32 {
+	if !frameHasExtendedHeader(frame) {
+		return 0
+	}
+	return atomic.LoadUint32((*uint32)(unsafe.Pointer(&frame[12])))
+}
+
+func frameHasRelocationTable(frame []byte) bool {
+	return frameFlags(frame)&frameFlagRelocationTable != 0
+}
+
+// frameState returns the in place decoding state of the frame,
+// ok is false if the frame does not hold the value of the signature
+func frameState(frame []byte, signature uint32) (state uint32, ok bool) {
+	_, _, state, ok = frameStateWord(frame, signature)
+	return
+}
+
+// frameHolds tells if the frame holds the value of the signature, no matter it is decoded in place or not
+func frameHolds(frame []byte, signature uint32) bool {
+	_, ok := frameState(frame, signature)
+	return ok
+}
+
+// frameStateWord returns the header word holding the state, and the state read from it
+func frameStateWord(frame []byte, signature uint32) (pWord *uint32, word uint32, state uint32, ok bool) {
+	if frameHasExtendedHeader(frame) {
+		pWord = (*uint32)(unsafe.Pointer(&frame[12]))
+		word = atomic.LoadUint32(pWord)
+		return pWord, word, word >> frameStateShift, frameSignature(frame) == signature
+	}
+	pWord = (*uint32)(unsafe.Pointer(&frame[4]))
+	word = atomic.LoadUint32(pWord)
+	for state, key := range frameStateKeys {
+		if word^key == signature {
+			return pWord, word, uint32(state), true
+		}
+	}
+	return pWord, word, 0, false
+}
+
+// switchFrameState moves the frame from one state to another atomically,
+// it fails if the frame is not in the expected state
+func switchFrameState(frame []byte, signature uint32, from uint32, to uint32) error {
+	for {
+		pWord, word, state, ok := frameStateWord(frame, signature)
+		if !ok {
+			return errSignatureMismatch
+		}
+		switch state {
+		case from:
+		case frameStateDecoding:
+			return ErrFrameBusy
+		case frameStateRelocated:
+			return errFrameRelocated
+		case frameStateBroken:
+			return errFrameBroken
+		default:
+			return errFrameNotRelocated
+		}
+		newWord := signature ^ frameStateKeys[to]
+		if frameHasExtendedHeader(frame) {
+			newWord = word&^frameStateMask | to<<frameStateShift
+		}
+		if atomic.CompareAndSwapUint32(pWord, word, newWord) {
+			return nil
+		}
+	}
+}
+
+// relocationTableOf returns the offsets (relative to the root value) of every pointer word in the frame
+// the table is stored as count (4 bytes) at the very end, preceded by count offsets (4 bytes each)
+func relocationTableOf(frame []byte) []uint32 {
+	size := int(frameSize(frame))
+	count := int(*(*uint32)(unsafe.Pointer(&frame[size-4])))
+	if count == 0 {
+		return nil
+	}
+	start := size - 4 - 4*count
+	return *(*[]uint32)(unsafe.Pointer(&sliceReadonlyHeader{
+		Data: unsafe.Pointer(&frame[start]), Len: count, Cap: count}))
+}
+
+// relocateFrame turns every relative offset listed in the relocation table into absolute pointer
+func relocateFrame(frame []byte) error {
+	table := relocationTableOf(frame)
+	// the values end where the table starts
+	end := uintptr(frameSize(frame)) - 4 - 4*uintptr(len(table)) - frameExtendedHeaderSize
+	root := frame[frameExtendedHeaderSize:]
+	// validate before writing anything, so that the frame is never left half relocated
+	for _, offset := range table {
+		if uintptr(offset)%unsafe.Sizeof(uintptr(0)) != 0 || uintptr(offset)+unsafe.Sizeof(uintptr(0)) > end {
+			return errPointerOutOfFrame
+		}
+		relOffset := *(*uintptr)(unsafe.Pointer(&root[offset]))
+		if relOffset == 0 || relOffset > end-uintptr(offset) {
+			return errPointerOutOfFrame
+		}
+	}
+	base := uintptr(unsafe.Pointer(&root[0]))
+	for _, offset := range table {
+		pWord := unsafe.Pointer(&root[offset])
+		*(*uintptr)(pWord) = base + uintptr(offset) + *(*uintptr)(pWord)
+	}
+	return nil
+}
+
+// freezeFrame is the reverse of relocateFrame, absolute pointers are turned back into relative offsets
+func freezeFrame(frame []byte) error {
+	root := frame[frameExtendedHeaderSize:]
+	base := uintptr(unsafe.Pointer(&root[0]))
+	end := uintptr(unsafe.Pointer(&frame[0])) + uintptr(frameSize(frame))
+	table := relocationTableOf(frame)
+	// validate before writing anything, so that the frame is never left half frozen
+	for _, offset := range table {
+		ptr := *(*uintptr)(unsafe.Pointer(&root[offset]))
+		if ptr <= base+uintptr(offset) || ptr > end {
+			return errPointerOutOfFrame
+		}
+	}
+	for _, offset := range table {
+		pWord := unsafe.Pointer(&root[offset])
+		*(*uintptr)(pWord) = *(*uintptr)(pWord) - base - uintptr(offset)
+	}
+	return nil
+}
+
+// moveFrame makes the pointers in the copy of the frame decoded in place point into the copy,
+// instead of the origin it is copied from
+func moveFrame(frame []byte, origin []byte) error {
+	root := frame[frameExtendedHeaderSize:]
+	originRoot := uintptr(unsafe.Pointer(&origin[frameExtendedHeaderSize]))
+	end := uintptr(unsafe.Pointer(&origin[0])) + uintptr(len(origin))
+	table := relocationTableOf(frame)
+	// validate before writing anything, so that the copy is never left half moved
+	for _, offset := range table {
+		ptr := *(*uintptr)(unsafe.Pointer(&root[offset]))
+		if ptr <= originRoot+uintptr(offset) || ptr > end {
+			return errPointerOutOfFrame
+		}
+	}
+	base := uintptr(unsafe.Pointer(&root[0]))
+	for _, offset := range table {
+		pWord := unsafe.Pointer(&root[offset])
+		*(*uintptr)(pWord) = *(*uintptr)(pWord) - originRoot + base
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package gocodec
 
 import (
+	"errors"
+	"hash"
+	"io"
 	"unsafe"
 	"reflect"
 	"sync"
@@ -23,14 +26,114 @@ var defaultAllocator = &DefaultAllocator{}
 
 type Config struct {
 	ReadonlyDecode bool
+	// RelocationTable appends the offsets of every pointer word to the frame,
+	// so that decoding is a linear pass and does not need the type
+	RelocationTable bool
+	// Canonical zeroes the struct padding, so that equal values are always encoded into identical bytes.
+	Canonical bool
+	// BinaryMarshaler encodes the types implementing both encoding.BinaryMarshaler and encoding.BinaryUnmarshaler
+	// as the out of line bytes returned by MarshalBinary, and decodes them by UnmarshalBinary.
+	// The value holding them is decoded onto heap instead of in place, if they have pointers.
+	BinaryMarshaler bool
+	// kept behind pointer, so that Config is still comparable
+	codecs *customCodecs
+}
+
+type customCodecs map[reflect.Type]customCodec
+
+type customCodec struct {
+	encoder ValEncoder
+	decoder ValDecoder
+}
+
+// RegisterCodec makes valType encoded and decoded by the given codec, instead of by its memory layout.
+// The encoder starts with the value copied as it is, and rewrites it with Stream.WriteWord and Stream.WriteOutOfLine.
+// The decoder reads the portable form with Iterator.Word and Iterator.OutOfLine, and writes the decoded form with
+// Iterator.SetWord and Iterator.SetPointer. Decoder must tell HasPointer if it needs to be called,
+// and is not called for frame with relocation table, where every word written by WriteOutOfLine
+// simply becomes the pointer to the out of line bytes.
+// Encoder and decoder must have the same signature, which should not collide with other types.
+// The codecs are copied on every registration, the copies of Config made before do not see the new codec.
+func (cfg *Config) RegisterCodec(valType reflect.Type, encoder ValEncoder, decoder ValDecoder) {
+	codecs := customCodecs{}
+	if cfg.codecs != nil {
+		for registeredType, codec := range *cfg.codecs {
+			codecs[registeredType] = codec
+		}
+	}
+	codecs[valType] = customCodec{encoder, decoder}
+	cfg.codecs = &codecs
 }
 
 type API interface {
 	Marshal(val interface{}) ([]byte, error)
+	MarshalPointer(ptr interface{}) ([]byte, error)
+	MarshalTo(dst []byte, val interface{}) (int, error)
+	EncodedSize(val interface{}) (int, error)
 	Unmarshal(buf []byte, candidatePointer interface{}) (interface{}, error)
 	UnmarshalCandidates(buf []byte, candidatePointers ...interface{}) (interface{}, error)
+	UnmarshalInto(buf []byte, ptr interface{}) error
+	Relocate(buf []byte) ([]byte, error)
+	Freeze(buf []byte, candidatePointer interface{}) error
+	CompileField(candidatePointer interface{}, path string) (*FieldHandle, error)
+	SetField(buf []byte, candidatePointer interface{}, path string, val interface{}) error
 	NewIterator(buf []byte) *Iterator
 	NewStream(buf []byte) *Stream
+	NewEncoder(w io.Writer) *Encoder
+	Dump(w io.Writer, buf []byte, candidatePointer interface{}) error
+	ToJSON(buf []byte, candidatePointer interface{}) ([]byte, error)
+	FromJSON(data []byte, candidatePointer interface{}) ([]byte, error)
+	Hash(h hash.Hash, val interface{}) ([]byte, error)
+	HashFrame(h hash.Hash, buf []byte, candidatePointer interface{}) ([]byte, error)
+	Diff(a []byte, b []byte, candidatePointer interface{}) ([]Difference, error)
+	NewSharedView(buf []byte, candidatePointers ...interface{}) (*SharedView, error)
+	NewRegistry() *Registry
+	NewMux() *Mux
+}
+
+var ErrShortBuffer = errors.New("short buffer")
+
+var errEncodedSizeChanged = errors.New("MarshalTo: the value is not encoded into the measured size")
+
+// frameSizer accumulates the bytes a value is going to be encoded into, without writing anything
+type frameSizer struct {
+	cfg *frozenConfig
+	// the end of the frame measured so far, header included
+	size     int
+	pointers int
+	err      error
+}
+
+// valMeasurer is implemented by the built-in encoders, which know the size without encoding the value
+type valMeasurer interface {
+	measure(ptr unsafe.Pointer, sizer *frameSizer)
+}
+
+// addOutOfLine counts an out of line value of given size and alignment, referenced by a relative offset
+func (sizer *frameSizer) addOutOfLine(size int, alignment int) {
+	sizer.size = int(alignUp(uintptr(sizer.size), uintptr(alignment))) + size
+	sizer.pointers++
+}
+
+// measure counts the out of line values of the value at ptr.
+// The encoder registered with Config.RegisterCodec is measured by encoding the value aside,
+// followed by zeros so that the out of line values are appended at the same alignment as in the frame.
+func (sizer *frameSizer) measure(encoder ValEncoder, ptr unsafe.Pointer) {
+	if measurer, isMeasurer := encoder.(valMeasurer); isMeasurer {
+		measurer.measure(ptr, sizer)
+		return
+	}
+	size := int(encoder.Type().Size())
+	gap := ((sizer.size-size)%frameAlign + frameAlign) % frameAlign
+	buf := make([]byte, size+gap)
+	copy(buf, ptrAsBytes(size, ptr))
+	stream := sizer.cfg.NewStream(buf)
+	encoder.Encode(ptr, stream)
+	if stream.Error != nil && sizer.err == nil {
+		sizer.err = stream.Error
+	}
+	sizer.size += len(stream.buf) - size - gap
+	sizer.pointers += len(stream.relocations)
 }
 
 type ValEncoder interface {
@@ -44,10 +147,14 @@ type RootEncoder interface {
 	Type() reflect.Type
 	Signature() uint32
 	EncodeEmptyInterface(ptr unsafe.Pointer, stream *Stream)
+	measureEmptyInterface(ptr unsafe.Pointer, sizer *frameSizer)
+	emitEmptyInterface(ptr unsafe.Pointer, emitter *frameEmitter) error
 }
 
 type ValDecoder interface {
 	Decode(iter *Iterator)
+	// Freeze turns the absolute pointers written by Decode back to relative offsets
+	Freeze(iter *Iterator)
 	Type() reflect.Type
 	IsNoop() bool
 	Signature() uint32
@@ -58,20 +165,51 @@ type RootDecoder interface {
 	Type() reflect.Type
 	Signature() uint32
 	DecodeEmptyInterface(ptr *emptyInterface, iter *Iterator)
+	Freeze(iter *Iterator)
 }
 
 type frozenConfig struct {
-	readonlyDecode bool
-	allocator      Allocator
-	decoderCache   *sync.Map
-	encoderCache   *sync.Map
+	readonlyDecode  bool
+	relocationTable bool
+	canonical       bool
+	binaryMarshaler bool
+	codecs          map[reflect.Type]customCodec
+	allocator       Allocator
+	decoderCache    *sync.Map
+	encoderCache    *sync.Map
+	// Encoders reused by Hash, so that their chunk is not allocated for every digest
+	hashEncoders *sync.Pool
+	// canonical without relocation table, decoding without changing the frame, with the same codecs
+	hashConfig *frozenConfig
 }
 
 func (cfg Config) Froze() API {
+	return cfg.froze()
+}
+
+func (cfg Config) froze() *frozenConfig {
 	api := &frozenConfig{
-		readonlyDecode: cfg.ReadonlyDecode,
-		decoderCache:   &sync.Map{},
-		encoderCache:   &sync.Map{},
+		readonlyDecode:  cfg.ReadonlyDecode,
+		relocationTable: cfg.RelocationTable,
+		canonical:       cfg.Canonical,
+		binaryMarshaler: cfg.BinaryMarshaler,
+		codecs:          map[reflect.Type]customCodec{},
+		decoderCache:    &sync.Map{},
+		encoderCache:    &sync.Map{},
+	}
+	api.hashEncoders = &sync.Pool{New: func() interface{} {
+		return api.NewEncoder(nil)
+	}}
+	if cfg.codecs != nil {
+		for valType, codec := range *cfg.codecs {
+			api.codecs[valType] = codec
+		}
+	}
+	hashConfig := Config{ReadonlyDecode: true, Canonical: true, BinaryMarshaler: cfg.BinaryMarshaler, codecs: cfg.codecs}
+	if cfg == hashConfig {
+		api.hashConfig = api
+	} else {
+		api.hashConfig = hashConfig.froze()
 	}
 	return api
 }
@@ -83,6 +221,18 @@ func Marshal(obj interface{}) ([]byte, error) {
 	return DefaultConfig.Marshal(obj)
 }
 
+func MarshalPointer(ptr interface{}) ([]byte, error) {
+	return DefaultConfig.MarshalPointer(ptr)
+}
+
+func MarshalTo(dst []byte, obj interface{}) (int, error) {
+	return DefaultConfig.MarshalTo(dst, obj)
+}
+
+func EncodedSize(obj interface{}) (int, error) {
+	return DefaultConfig.EncodedSize(obj)
+}
+
 func Unmarshal(buf []byte, candidatePointer interface{}) (interface{}, error) {
 	return DefaultConfig.Unmarshal(buf, candidatePointer)
 }
@@ -91,6 +241,14 @@ func UnmarshalCandidates(buf []byte, candidatePointers ...interface{}) (interfac
 	return DefaultConfig.UnmarshalCandidates(buf, candidatePointers...)
 }
 
+func Relocate(buf []byte) ([]byte, error) {
+	return DefaultConfig.Relocate(buf)
+}
+
+func Freeze(buf []byte, candidatePointer interface{}) error {
+	return DefaultConfig.Freeze(buf, candidatePointer)
+}
+
 func NewIterator(buf []byte) *Iterator {
 	return DefaultConfig.NewIterator(buf)
 }
@@ -105,6 +263,49 @@ func (cfg *frozenConfig) Marshal(val interface{}) ([]byte, error) {
 	return stream.Buffer(), stream.Error
 }
 
+func (cfg *frozenConfig) MarshalPointer(ptr interface{}) ([]byte, error) {
+	stream := cfg.NewStream(nil)
+	stream.MarshalPointer(ptr)
+	return stream.Buffer(), stream.Error
+}
+
+func (cfg *frozenConfig) MarshalTo(dst []byte, val interface{}) (int, error) {
+	size, err := cfg.EncodedSize(val)
+	if err != nil {
+		return 0, err
+	}
+	if size > len(dst) {
+		return 0, ErrShortBuffer
+	}
+	// the capacity is exact, append writes into dst unless the value is encoded larger than measured
+	stream := cfg.NewStream(dst[:0:size])
+	stream.Marshal(val)
+	if stream.Error != nil {
+		return 0, stream.Error
+	}
+	if out := stream.Buffer(); len(out) != size || &out[0] != &dst[0] {
+		return 0, errEncodedSizeChanged
+	}
+	return size, nil
+}
+
+func (cfg *frozenConfig) EncodedSize(val interface{}) (int, error) {
+	encoder, err := encoderOfType(cfg, reflect.TypeOf(val))
+	if err != nil {
+		return 0, err
+	}
+	sizer := &frameSizer{cfg: cfg, size: int(headerSizeOf(cfg.relocationTable))}
+	encoder.measureEmptyInterface(ptrOfEmptyInterface(val), sizer)
+	if sizer.err != nil {
+		return 0, sizer.err
+	}
+	size := sizer.size
+	if cfg.relocationTable {
+		size += 4*sizer.pointers + 4
+	}
+	return int(alignUp(uintptr(size), frameAlign)), nil
+}
+
 func (cfg *frozenConfig) Unmarshal(buf []byte, candidatePointer interface{}) (interface{}, error) {
 	iter := cfg.NewIterator(buf)
 	val := iter.Unmarshal(candidatePointer)
@@ -116,3 +317,15 @@ func (cfg *frozenConfig) UnmarshalCandidates(buf []byte, candidatePointers ...in
 	val := iter.UnmarshalCandidates(candidatePointers...)
 	return val, iter.Error
 }
+
+func (cfg *frozenConfig) Relocate(buf []byte) ([]byte, error) {
+	iter := cfg.NewIterator(buf)
+	root := iter.Relocate()
+	return root, iter.Error
+}
+
+func (cfg *frozenConfig) Freeze(buf []byte, candidatePointer interface{}) error {
+	iter := cfg.NewIterator(buf)
+	iter.Freeze(candidatePointer)
+	return iter.Error
+}
@@ -14,12 +14,41 @@ func (encoder *arrayEncoder) Encode(prArray unsafe.Pointer, stream *Stream) {
 		return
 	}
 	cursor := stream.cursor
-	prElem := uintptr(prArray)
+	// the element pointer is derived from prArray in one expression,
+	// a uintptr kept across the calls would not keep the array alive
 	for i := 0; i < encoder.arrayLength; i++ {
 		stream.cursor = cursor // stream.cursor will change in the elemEncoder
-		encoder.elemEncoder.Encode(unsafe.Pointer(prElem), stream)
+		encoder.elemEncoder.Encode(unsafe.Pointer(uintptr(prArray)+uintptr(i)*encoder.elementSize), stream)
 		cursor = cursor + encoder.elementSize
-		prElem = prElem + encoder.elementSize
+	}
+}
+
+func (encoder *arrayEncoder) measure(prArray unsafe.Pointer, sizer *frameSizer) {
+	if encoder.IsNoop() {
+		return
+	}
+	for i := 0; i < encoder.arrayLength; i++ {
+		sizer.measure(encoder.elemEncoder, unsafe.Pointer(uintptr(prArray)+uintptr(i)*encoder.elementSize))
+	}
+}
+
+func (encoder *arrayEncoder) layout(prArray unsafe.Pointer, emitter *frameEmitter, at emitterWord) {
+	if encoder.IsNoop() {
+		return
+	}
+	for i := 0; i < encoder.arrayLength; i++ {
+		emitter.layoutValue(encoder.elemEncoder, unsafe.Pointer(uintptr(prArray)+uintptr(i)*encoder.elementSize),
+			emitterWord{at.block, at.offset + uintptr(i)*encoder.elementSize})
+	}
+}
+
+func (encoder *arrayEncoder) emit(prArray unsafe.Pointer, emitter *frameEmitter, at uintptr) {
+	if encoder.IsNoop() {
+		return
+	}
+	for i := 0; i < encoder.arrayLength; i++ {
+		emitter.emitValue(encoder.elemEncoder, unsafe.Pointer(uintptr(prArray)+uintptr(i)*encoder.elementSize),
+			at+uintptr(i)*encoder.elementSize)
 	}
 }
 
@@ -46,6 +75,9 @@ func (decoder *arrayDecoderWithoutPointer) Decode(iter *Iterator) {
 	}
 }
 
+func (decoder *arrayDecoderWithoutPointer) Freeze(iter *Iterator) {
+}
+
 func (decoder *arrayDecoderWithoutPointer) IsNoop() bool {
 	return decoder.elemDecoder == nil
 }
@@ -72,6 +104,18 @@ func (decoder *arrayDecoderWithPointer) Decode(iter *Iterator) {
 	}
 }
 
+func (decoder *arrayDecoderWithPointer) Freeze(iter *Iterator) {
+	if decoder.IsNoop() {
+		return
+	}
+	cursor := iter.cursor
+	for i := 0; i < decoder.arrayLength; i++ {
+		iter.cursor = cursor // iter.cursor will change in elemDecoder
+		decoder.elemDecoder.Freeze(iter)
+		cursor = cursor[decoder.elementSize:]
+	}
+}
+
 func (decoder *arrayDecoderWithPointer) IsNoop() bool {
 	return decoder.elemDecoder == nil
 }
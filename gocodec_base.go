@@ -14,6 +14,11 @@ func newBaseCodec(valType reflect.Type, signature uint32) *BaseCodec {
 	return &BaseCodec{valType: valType, signature: signature}
 }
 
+// NewBaseCodec is meant to be embedded by the codec registered with Config.RegisterCodec
+func NewBaseCodec(valType reflect.Type, signature uint32) *BaseCodec {
+	return newBaseCodec(valType, signature)
+}
+
 func (codec *BaseCodec) Encode(stream *Stream) {
 	panic("not implemented")
 }
@@ -49,5 +54,17 @@ func (codec *NoopCodec) IsNoop() bool {
 func (codec *NoopCodec) Decode(iter *Iterator) {
 }
 
+func (codec *NoopCodec) Freeze(iter *Iterator) {
+}
+
 func (codec *NoopCodec) Encode(ptr unsafe.Pointer, stream *Stream) {
 }
+
+func (codec *NoopCodec) measure(ptr unsafe.Pointer, sizer *frameSizer) {
+}
+
+func (codec *NoopCodec) layout(ptr unsafe.Pointer, emitter *frameEmitter, at emitterWord) {
+}
+
+func (codec *NoopCodec) emit(ptr unsafe.Pointer, emitter *frameEmitter, at uintptr) {
+}
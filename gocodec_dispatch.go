@@ -44,37 +44,53 @@ func encoderOfType(cfg *frozenConfig, valType reflect.Type) (RootEncoder, error)
 	return rootEncoder, err
 }
 
+// valEncoderOf unwraps the encoder of the root value
+func valEncoderOf(encoder RootEncoder) ValEncoder {
+	switch encoder := encoder.(type) {
+	case *rootEncoder:
+		return encoder.encoder
+	case *singlePointerFix:
+		return encoder.encoder
+	}
+	return nil
+}
+
 func wrapRootEncoder(encoder ValEncoder) RootEncoder {
 	valType := encoder.Type()
-	valKind := valType.Kind()
 	rootEncoder := rootEncoder{valType, encoder.Signature(), encoder}
-	switch valKind {
-	case reflect.Struct:
-		if valType.NumField() == 1 && valType.Field(0).Type.Kind() == reflect.Ptr {
-			return &singlePointerFix{rootEncoder}
-		}
-	case reflect.Array:
-		if valType.Len() == 1 && valType.Elem().Kind() == reflect.Ptr {
-			return &singlePointerFix{rootEncoder}
-		}
-	case reflect.Ptr:
+	if typeIsDirectIface(valType) {
 		return &singlePointerFix{rootEncoder}
 	}
 	return &rootEncoder
 }
 
+// typeIsDirectIface tells if interface{} holds the value itself instead of pointer to it,
+// which is the case for pointer shaped type, as long as struct and array of single element are pointer shaped
+func typeIsDirectIface(valType reflect.Type) bool {
+	switch valType.Kind() {
+	case reflect.Ptr, reflect.Chan, reflect.Map, reflect.Func, reflect.UnsafePointer:
+		return true
+	case reflect.Struct:
+		return valType.NumField() == 1 && typeIsDirectIface(valType.Field(0).Type)
+	case reflect.Array:
+		return valType.Len() == 1 && typeIsDirectIface(valType.Elem())
+	}
+	return false
+}
+
 func decoderOfType(cfg *frozenConfig, valType reflect.Type) (RootDecoder, error) {
 	cacheKey := valType
 	rootDecoder := cfg.getDecoderFromCache(cacheKey)
 	if rootDecoder != nil {
 		return rootDecoder, nil
 	}
-	decoder, err := createDecoderOfType(cfg, valType)
+	onHeap := typeNeedsHeap(cfg, valType)
+	decoder, err := createDecoderOfType(cfg, valType, onHeap)
 	if err != nil {
 		return nil, err
 	}
-	if cfg.readonlyDecode && decoder.HasPointer() {
-		rootDecoder = &rootDecoderWithCopy{valType, decoder.Signature(), decoder}
+	if onHeap || cfg.readonlyDecode && decoder.HasPointer() {
+		rootDecoder = &rootDecoderWithCopy{valType, decoder.Signature(), decoder, onHeap}
 	} else {
 		rootDecoder = &rootDecoderWithoutCopy{valType, decoder.Signature(), decoder}
 	}
@@ -83,37 +99,81 @@ func decoderOfType(cfg *frozenConfig, valType reflect.Type) (RootDecoder, error)
 }
 
 func createEncoderOfType(cfg *frozenConfig, valType reflect.Type) (ValEncoder, error) {
+	if codec, found := cfg.codecs[valType]; found {
+		return codec.encoder, nil
+	}
+	if valType == timeType {
+		if cfg.relocationTable {
+			return nil, errTimeWithRelocationTable
+		}
+		return &timeCodec{BaseCodec: *newBaseCodec(valType, timeSignature)}, nil
+	}
+	if cfg.binaryMarshaler && isBinaryMarshaler(valType) {
+		if cfg.relocationTable {
+			return nil, errBinaryMarshalerWithRelocationTable
+		}
+		return newBinaryMarshalerCodec(valType), nil
+	}
 	valKind := valType.Kind()
 	switch valKind {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-		reflect.Uintptr, reflect.Float32, reflect.Float64:
+		reflect.Uintptr, reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
 		return &NoopCodec{BaseCodec: *newBaseCodec(valType, uint32(valKind))}, nil
 	case reflect.String:
 		return &stringCodec{BaseCodec: *newBaseCodec(valType, uint32(valKind))}, nil
 	case reflect.Struct:
 		signature := uint32(valKind)
 		fields := make([]structFieldEncoder, 0, valType.NumField())
+		members := make([]structFieldEncoder, 0, valType.NumField())
 		for i := 0; i < valType.NumField(); i++ {
+			tag, err := fieldTagOf(valType.Field(i))
+			if err != nil {
+				return nil, withPathElement("."+valType.Field(i).Name, err)
+			}
+			if tag == fieldTagSkip {
+				signature = 31*signature + skippedFieldSignature
+				field := structFieldEncoder{
+					name:   valType.Field(i).Name,
+					offset: valType.Field(i).Offset,
+					encoder: &skippedFieldEncoder{
+						BaseCodec: *newBaseCodec(valType.Field(i).Type, skippedFieldSignature)},
+				}
+				fields = append(fields, field)
+				continue
+			}
+			if tag == fieldTagCopy && cfg.relocationTable {
+				return nil, withPathElement("."+valType.Field(i).Name, errCopyWithRelocationTable)
+			}
 			encoder, err := createEncoderOfType(cfg, valType.Field(i).Type)
 			if err != nil {
-				return nil, err
+				return nil, withPathElement("."+valType.Field(i).Name, err)
+			}
+			if tag == fieldTagCopy {
+				signature = 31*signature + (31*encoder.Signature() + copiedFieldSignature)
+			} else {
+				signature = 31*signature + encoder.Signature()
+			}
+			field := structFieldEncoder{
+				name:    valType.Field(i).Name,
+				offset:  valType.Field(i).Offset,
+				encoder: encoder,
 			}
-			signature = 31*signature + encoder.Signature()
 			if !encoder.IsNoop() {
-				fields = append(fields, structFieldEncoder{
-					offset:  valType.Field(i).Offset,
-					encoder: encoder,
-				})
+				fields = append(fields, field)
 			}
+			members = append(members, field)
+		}
+		encoder := &structEncoder{BaseCodec: *newBaseCodec(valType, signature), fields: fields, members: members}
+		if cfg.canonical {
+			encoder.gaps = structGapsOf(valType)
 		}
-		encoder := &structEncoder{BaseCodec: *newBaseCodec(valType, signature), fields: fields}
 		return encoder, nil
 	case reflect.Array:
 		signature := uint32(valKind)
 		elemEncoder, err := createEncoderOfType(cfg, valType.Elem())
 		if err != nil {
-			return nil, err
+			return nil, withPathElement("[]", err)
 		}
 		signature = 31*signature + elemEncoder.Signature()
 		if elemEncoder.IsNoop() {
@@ -130,14 +190,14 @@ func createEncoderOfType(cfg *frozenConfig, valType reflect.Type) (ValEncoder, e
 		signature := uint32(valKind)
 		elemEncoder, err := createEncoderOfType(cfg, valType.Elem())
 		if err != nil {
-			return nil, err
+			return nil, withPathElement("[]", err)
 		}
 		signature = 31*signature + elemEncoder.Signature()
 		if elemEncoder.IsNoop() {
 			elemEncoder = nil
 		}
 		return &sliceEncoder{BaseCodec: *newBaseCodec(valType, signature),
-			elemSize: int(valType.Elem().Size()), elemEncoder: elemEncoder}, nil
+			elemSize: int(valType.Elem().Size()), elemAlign: valType.Elem().Align(), elemEncoder: elemEncoder}, nil
 	case reflect.Ptr:
 		signature := uint32(valKind)
 		elemEncoder, err := createEncoderOfType(cfg, valType.Elem())
@@ -151,12 +211,89 @@ func createEncoderOfType(cfg *frozenConfig, valType reflect.Type) (ValEncoder, e
 	return nil, fmt.Errorf("unsupported type %s", valType.String())
 }
 
-func createDecoderOfType(cfg *frozenConfig, valType reflect.Type) (ValDecoder, error) {
+// pathError tells where in the value the error happened, such as Root.Items[3].Name
+type pathError struct {
+	path string
+	err  interface{}
+}
+
+func (err *pathError) Error() string {
+	return fmt.Sprintf("Root%s: %v", err.path, err.err)
+}
+
+// withPathElement prepends the element to the path, as the error goes up from the innermost value.
+// err can be either error or recovered panic.
+func withPathElement(element string, err interface{}) *pathError {
+	if pathErr, isPathErr := err.(*pathError); isPathErr {
+		pathErr.path = element + pathErr.path
+		return pathErr
+	}
+	return &pathError{path: element, err: err}
+}
+
+func structGapsOf(valType reflect.Type) []structGap {
+	var gaps []structGap
+	end := uintptr(0)
+	for i := 0; i < valType.NumField(); i++ {
+		field := valType.Field(i)
+		if field.Offset > end {
+			gaps = append(gaps, structGap{offset: end, size: field.Offset - end})
+		}
+		end = field.Offset + field.Type.Size()
+	}
+	if valType.Size() > end {
+		gaps = append(gaps, structGap{offset: end, size: valType.Size() - end})
+	}
+	return gaps
+}
+
+// typeNeedsHeap tells if decoding the type creates values on heap, such as the fields tagged with gocodec:"copy"
+// and the values created by UnmarshalBinary.
+// The frame is not scanned by garbage collector, it can not hold the pointers to them,
+// so the value is decoded onto heap as a whole, everything on the way to them is copied as well.
+func typeNeedsHeap(cfg *frozenConfig, valType reflect.Type) bool {
+	if _, found := cfg.codecs[valType]; found || valType == timeType {
+		return false
+	}
+	if cfg.binaryMarshaler && isBinaryMarshaler(valType) {
+		return typeHasPointer(valType)
+	}
+	switch valType.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Ptr:
+		return typeNeedsHeap(cfg, valType.Elem())
+	case reflect.Struct:
+		for i := 0; i < valType.NumField(); i++ {
+			field := valType.Field(i)
+			tag, _ := fieldTagOf(field)
+			switch {
+			case tag == fieldTagSkip:
+			case tag == fieldTagCopy && typeHasPointer(field.Type):
+				return true
+			case typeNeedsHeap(cfg, field.Type):
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// createDecoderOfType creates the decoder of the value within the root,
+// onHeap copies every out of line value with pointers onto heap, instead of referencing it in the frame
+func createDecoderOfType(cfg *frozenConfig, valType reflect.Type, onHeap bool) (ValDecoder, error) {
+	if codec, found := cfg.codecs[valType]; found {
+		return codec.decoder, nil
+	}
+	if valType == timeType {
+		return &timeCodec{BaseCodec: *newBaseCodec(valType, timeSignature)}, nil
+	}
+	if cfg.binaryMarshaler && isBinaryMarshaler(valType) {
+		return newBinaryMarshalerCodec(valType), nil
+	}
 	valKind := valType.Kind()
 	switch valKind {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-		reflect.Uintptr, reflect.Float32, reflect.Float64:
+		reflect.Uintptr, reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
 		return &NoopCodec{BaseCodec: *newBaseCodec(valType, uint32(valKind))}, nil
 	case reflect.String:
 		return &stringCodec{BaseCodec: *newBaseCodec(valType, uint32(valKind))}, nil
@@ -165,14 +302,31 @@ func createDecoderOfType(cfg *frozenConfig, valType reflect.Type) (ValDecoder, e
 		signature := uint32(valKind)
 		hasPointer := false
 		for i := 0; i < valType.NumField(); i++ {
-			decoder, err := createDecoderOfType(cfg, valType.Field(i).Type)
+			tag, err := fieldTagOf(valType.Field(i))
+			if err != nil {
+				return nil, withPathElement("."+valType.Field(i).Name, err)
+			}
+			if tag == fieldTagSkip {
+				// encoded as zeros, nothing to decode
+				signature = 31*signature + skippedFieldSignature
+				continue
+			}
+			decoder, err := createDecoderOfType(cfg, valType.Field(i).Type, onHeap)
 			if err != nil {
-				return nil, err
+				return nil, withPathElement("."+valType.Field(i).Name, err)
+			}
+			if tag == fieldTagCopy {
+				signature = 31*signature + (31*decoder.Signature() + copiedFieldSignature)
+				if decoder.HasPointer() {
+					decoder = &copiedFieldDecoder{
+						BaseCodec: *newBaseCodec(valType.Field(i).Type, decoder.Signature()), decoder: decoder}
+				}
+			} else {
+				signature = 31*signature + decoder.Signature()
 			}
 			if decoder.HasPointer() {
 				hasPointer = true
 			}
-			signature = 31*signature + decoder.Signature()
 			if !decoder.IsNoop() {
 				fields = append(fields, structFieldDecoder{
 					offset:  valType.Field(i).Offset,
@@ -186,9 +340,9 @@ func createDecoderOfType(cfg *frozenConfig, valType reflect.Type) (ValDecoder, e
 		return &structDecoderWithoutPointer{BaseCodec: *newBaseCodec(valType, signature), fields: fields}, nil
 	case reflect.Array:
 		signature := uint32(valKind)
-		elemDecoder, err := createDecoderOfType(cfg, valType.Elem())
+		elemDecoder, err := createDecoderOfType(cfg, valType.Elem(), onHeap)
 		if err != nil {
-			return nil, err
+			return nil, withPathElement("[]", err)
 		}
 		signature = 31*signature + elemDecoder.Signature()
 		hasPointer := elemDecoder.HasPointer()
@@ -211,13 +365,13 @@ func createDecoderOfType(cfg *frozenConfig, valType reflect.Type) (ValDecoder, e
 		}, nil
 	case reflect.Slice:
 		signature := uint32(valKind)
-		elemDecoder, err := createDecoderOfType(cfg, valType.Elem())
+		elemDecoder, err := createDecoderOfType(cfg, valType.Elem(), onHeap)
 		if err != nil {
-			return nil, err
+			return nil, withPathElement("[]", err)
 		}
 		signature = 31*signature + elemDecoder.Signature()
 		shouldCopy := false
-		if elemDecoder.HasPointer() && cfg.readonlyDecode {
+		if elemDecoder.HasPointer() && (cfg.readonlyDecode || onHeap) {
 			shouldCopy = true
 		}
 		if elemDecoder.IsNoop() {
@@ -225,19 +379,20 @@ func createDecoderOfType(cfg *frozenConfig, valType reflect.Type) (ValDecoder, e
 		}
 		if shouldCopy {
 			return &sliceDecoderWithCopy{BaseCodec: *newBaseCodec(valType, signature),
-				elemSize: int(valType.Elem().Size()), elemDecoder: elemDecoder}, nil
+				elemSize: int(valType.Elem().Size()), elemDecoder: elemDecoder, onHeap: onHeap}, nil
 		}
 		return &sliceDecoderWithoutCopy{BaseCodec: *newBaseCodec(valType, signature),
 			elemSize: int(valType.Elem().Size()), elemDecoder: elemDecoder}, nil
 	case reflect.Ptr:
 		signature := uint32(valKind)
-		elemDecoder, err := createDecoderOfType(cfg, valType.Elem())
+		elemDecoder, err := createDecoderOfType(cfg, valType.Elem(), onHeap)
 		if err != nil {
 			return nil, err
 		}
 		signature = 31*signature + elemDecoder.Signature()
-		if elemDecoder.HasPointer() && cfg.readonlyDecode {
-			return &pointerDecoderWithCopy{BaseCodec: *newBaseCodec(valType, signature), elemDecoder: elemDecoder}, nil
+		if elemDecoder.HasPointer() && (cfg.readonlyDecode || onHeap) {
+			return &pointerDecoderWithCopy{BaseCodec: *newBaseCodec(valType, signature),
+				elemDecoder: elemDecoder, onHeap: onHeap}, nil
 		}
 		return &pointerDecoderWithoutCopy{BaseCodec: *newBaseCodec(valType, signature), elemDecoder: elemDecoder}, nil
 	}
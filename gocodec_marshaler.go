@@ -0,0 +1,120 @@
+package gocodec
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"unsafe"
+)
+
+// with Config.BinaryMarshaler, the value is encoded as
+// relative offset to the out of line blob (8 bytes) + zeros to fill the value size
+// the blob is length (8 bytes) + bytes returned by MarshalBinary
+var binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+var binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+const binaryMarshalerSignature = uint32(0x62696e) // "bin"
+
+var errBinaryMarshalerWithRelocationTable = errors.New("BinaryMarshaler can not be encoded with relocation table")
+
+// isBinaryMarshaler tells if the type is encoded by MarshalBinary when Config.BinaryMarshaler is on,
+// the value must be large enough to hold the relative offset to the blob
+func isBinaryMarshaler(valType reflect.Type) bool {
+	switch valType.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return false
+	}
+	ptrType := reflect.PtrTo(valType)
+	return valType.Size() >= unsafe.Sizeof(uintptr(0)) &&
+		ptrType.Implements(binaryMarshalerType) && ptrType.Implements(binaryUnmarshalerType)
+}
+
+type binaryMarshalerCodec struct {
+	BaseCodec
+}
+
+// newBinaryMarshalerCodec marks the signature, so that the frame can not be taken as the memory layout of same size,
+// the type name is mixed in, as the blob of one type means nothing to another type of the same size
+func newBinaryMarshalerCodec(valType reflect.Type) *binaryMarshalerCodec {
+	typeName := valType.String()
+	if valType.Name() != "" {
+		typeName = valType.PkgPath() + "." + valType.Name()
+	}
+	nameHash := fnv.New32a()
+	nameHash.Write([]byte(typeName))
+	signature := 31*(31*binaryMarshalerSignature+uint32(valType.Size())) + nameHash.Sum32()
+	return &binaryMarshalerCodec{BaseCodec: *newBaseCodec(valType, signature)}
+}
+
+func (codec *binaryMarshalerCodec) marshal(ptr unsafe.Pointer) ([]byte, error) {
+	marshaler := reflect.NewAt(codec.valType, ptr).Interface().(encoding.BinaryMarshaler)
+	return marshaler.MarshalBinary()
+}
+
+func (codec *binaryMarshalerCodec) Encode(ptr unsafe.Pointer, stream *Stream) {
+	data, err := codec.marshal(ptr)
+	if err != nil {
+		stream.ReportError("MarshalBinary", fmt.Errorf("%s: %s", codec.valType.String(), err))
+		return
+	}
+	self := stream.buf[stream.cursor : stream.cursor+codec.valType.Size()]
+	for i := range self {
+		self[i] = 0
+	}
+	writeBlob(stream, data)
+}
+
+// writeBlob appends the bytes returned by MarshalBinary, the word pointing to them is at stream.cursor
+func writeBlob(stream *Stream, data []byte) {
+	length := uint64(len(data))
+	stream.align(frameAlign)
+	stream.writeRelOffset()
+	stream.buf = append(stream.buf, ptrAsBytes(8, unsafe.Pointer(&length))...)
+	stream.buf = append(stream.buf, data...)
+}
+
+// layout keeps the blob returned by MarshalBinary, so that it is marshaled only once
+func (codec *binaryMarshalerCodec) layout(ptr unsafe.Pointer, emitter *frameEmitter, at emitterWord) {
+	data, err := codec.marshal(ptr)
+	if err != nil {
+		emitter.err = fmt.Errorf("MarshalBinary: %s: %s", codec.valType.String(), err)
+		return
+	}
+	length := uint64(len(data))
+	blob := append(append([]byte(nil), ptrAsBytes(8, unsafe.Pointer(&length))...), data...)
+	emitter.relocate(at)
+	emitter.addBlock(emitterBlock{size: uintptr(len(blob)), align: frameAlign, data: blob})
+}
+
+func (codec *binaryMarshalerCodec) emit(ptr unsafe.Pointer, emitter *frameEmitter, at uintptr) {
+	self := emitter.self[at : at+codec.valType.Size()]
+	for i := range self {
+		self[i] = 0
+	}
+	emitter.emitData(at)
+}
+
+func (codec *binaryMarshalerCodec) Decode(iter *Iterator) {
+	relOffset := *(*uintptr)(unsafe.Pointer(&iter.cursor[0]))
+	blob := iter.cursor[relOffset:]
+	length := *(*uint64)(unsafe.Pointer(&blob[0]))
+	data := blob[8 : 8+length]
+	obj := reflect.New(codec.valType)
+	if err := obj.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+		iter.ReportError("UnmarshalBinary", fmt.Errorf("%s: %s", codec.valType.String(), err))
+		return
+	}
+	// the value holding it is decoded onto heap if it has pointers, see typeNeedsHeap
+	reflect.NewAt(codec.valType, unsafe.Pointer(&iter.self[0])).Elem().Set(obj.Elem())
+}
+
+// Freeze is not supported, as the blob is lost after decoding
+func (codec *binaryMarshalerCodec) Freeze(iter *Iterator) {
+	iter.ReportError("Freeze", fmt.Errorf("can not freeze %s decoded by UnmarshalBinary", codec.valType.String()))
+}
+
+func (codec *binaryMarshalerCodec) HasPointer() bool {
+	return true
+}
@@ -17,13 +17,39 @@ func (encoder *pointerEncoder) Encode(prPointer unsafe.Pointer, stream *Stream)
 		return
 	}
 	valAsBytes := ptrAsBytes(int(encoder.elemEncoder.Type().Size()), ptr)
-	pwPointer := unsafe.Pointer(&stream.buf[stream.cursor])
-	*(*uintptr)(pwPointer) = uintptr(len(stream.buf)) - stream.cursor
+	stream.align(uintptr(encoder.elemEncoder.Type().Align()))
+	stream.writeRelOffset()
 	stream.cursor = uintptr(len(stream.buf))
 	stream.buf = append(stream.buf, valAsBytes...)
 	encoder.elemEncoder.Encode(ptr, stream)
 }
 
+func (encoder *pointerEncoder) measure(prPointer unsafe.Pointer, sizer *frameSizer) {
+	ptr := *(*unsafe.Pointer)(prPointer)
+	if uintptr(ptr) == 0 {
+		return
+	}
+	sizer.addOutOfLine(int(encoder.elemEncoder.Type().Size()), encoder.elemEncoder.Type().Align())
+	sizer.measure(encoder.elemEncoder, ptr)
+}
+
+func (encoder *pointerEncoder) layout(prPointer unsafe.Pointer, emitter *frameEmitter, at emitterWord) {
+	ptr := *(*unsafe.Pointer)(prPointer)
+	if uintptr(ptr) == 0 {
+		return
+	}
+	elemType := encoder.elemEncoder.Type()
+	emitter.layoutValues(encoder.elemEncoder, ptr, elemType.Size(), uintptr(elemType.Align()), 1, at)
+}
+
+func (encoder *pointerEncoder) emit(prPointer unsafe.Pointer, emitter *frameEmitter, at uintptr) {
+	ptr := *(*unsafe.Pointer)(prPointer)
+	if uintptr(ptr) == 0 {
+		return
+	}
+	emitter.emitValues(encoder.elemEncoder, ptr, encoder.elemEncoder.Type().Size(), 1, at)
+}
+
 type pointerDecoderWithoutCopy struct {
 	BaseCodec
 	elemDecoder ValDecoder
@@ -46,11 +72,22 @@ func (decoder *pointerDecoderWithoutCopy) Decode(iter *Iterator) {
 		return
 	}
 	iter.cursor = iter.cursor[relOffset:]
-	*(*uintptr)(unsafe.Pointer(&iter.self[0])) = uintptr(unsafe.Pointer(&iter.cursor[0]))
+	iter.setPointer(0, unsafe.Pointer(&iter.cursor[0]))
 	iter.self = iter.cursor
 	decoder.elemDecoder.Decode(iter)
 }
 
+func (decoder *pointerDecoderWithoutCopy) Freeze(iter *Iterator) {
+	pPtr := unsafe.Pointer(&iter.cursor[0])
+	relOffset := iter.relOffsetOf(*(*uintptr)(pPtr))
+	if relOffset == 0 {
+		return
+	}
+	*(*uintptr)(pPtr) = relOffset
+	iter.cursor = iter.cursor[relOffset:]
+	decoder.elemDecoder.Freeze(iter)
+}
+
 func (decoder *pointerDecoderWithoutCopy) HasPointer() bool {
 	return true
 }
@@ -58,6 +95,7 @@ func (decoder *pointerDecoderWithoutCopy) HasPointer() bool {
 type pointerDecoderWithCopy struct {
 	BaseCodec
 	elemDecoder ValDecoder
+	onHeap      bool
 }
 
 func (decoder *pointerDecoderWithCopy) Decode(iter *Iterator) {
@@ -77,12 +115,24 @@ func (decoder *pointerDecoderWithCopy) Decode(iter *Iterator) {
 		return
 	}
 	iter.cursor = iter.cursor[relOffset:]
-	copied := iter.allocator.Allocate(iter.objectSeq, iter.cursor[:decoder.elemDecoder.Type().Size()])
-	*(*uintptr)(unsafe.Pointer(&iter.self[0])) = uintptr(unsafe.Pointer(&copied[0]))
+	elemType := decoder.elemDecoder.Type()
+	copied := iter.allocate(decoder.onHeap, elemType, 1, iter.cursor[:elemType.Size()])
+	iter.setPointer(0, unsafe.Pointer(&copied[0]))
 	iter.self = copied
 	decoder.elemDecoder.Decode(iter)
 }
 
+func (decoder *pointerDecoderWithCopy) Freeze(iter *Iterator) {
+	pPtr := unsafe.Pointer(&iter.cursor[0])
+	relOffset := iter.relOffsetOf(*(*uintptr)(pPtr))
+	if relOffset == 0 {
+		return
+	}
+	*(*uintptr)(pPtr) = relOffset
+	iter.cursor = iter.cursor[relOffset:]
+	decoder.elemDecoder.Freeze(iter)
+}
+
 func (decoder *pointerDecoderWithCopy) HasPointer() bool {
 	return true
 }
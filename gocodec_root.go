@@ -18,6 +18,15 @@ func (encoder *rootEncoder) EncodeEmptyInterface(ptr unsafe.Pointer, stream *Str
 	encoder.encoder.Encode(ptr, stream)
 }
 
+func (encoder *rootEncoder) measureEmptyInterface(ptr unsafe.Pointer, sizer *frameSizer) {
+	sizer.size += int(encoder.valType.Size())
+	sizer.measure(encoder.encoder, ptr)
+}
+
+func (encoder *rootEncoder) emitEmptyInterface(ptr unsafe.Pointer, emitter *frameEmitter) error {
+	return emitter.emitRoot(encoder.encoder, ptr, encoder.signature)
+}
+
 func (encoder *rootEncoder) Signature() uint32 {
 	return encoder.signature
 }
@@ -30,6 +39,8 @@ type rootDecoderWithCopy struct {
 	valType   reflect.Type
 	signature uint32
 	decoder   ValDecoder
+	// the value holds pointers to heap, it is copied onto heap to be seen by garbage collector
+	onHeap bool
 }
 
 func (decoder *rootDecoderWithCopy) Signature() uint32 {
@@ -41,12 +52,74 @@ func (decoder *rootDecoderWithCopy) Type() reflect.Type {
 }
 
 func (decoder *rootDecoderWithCopy) DecodeEmptyInterface(ptr *emptyInterface, iter *Iterator) {
-	iter.self = iter.allocator.Allocate(iter.objectSeq, iter.buf[8:8+decoder.Type().Size()])
+	headerSize := frameHeaderSizeOf(iter.buf)
+	if frameHasRelocationTable(iter.buf) && decoder.onHeap {
+		iter.ReportError("DecodeVal", errOnHeapWithRelocationTable)
+		return
+	}
+	switch state, _ := frameState(iter.buf, decoder.signature); state {
+	case frameStateRelocated:
+		decoder.decodeRelocated(ptr, iter)
+		return
+	case frameStateDecoding:
+		iter.ReportError("DecodeVal", ErrFrameBusy)
+		return
+	case frameStateBroken:
+		iter.ReportError("DecodeVal", errFrameBroken)
+		return
+	}
+	if frameHasRelocationTable(iter.buf) {
+		copied := iter.allocator.Allocate(iter.objectSeq, iter.buf[:iter.NextSize()])
+		if err := relocateFrame(copied); err != nil {
+			iter.ReportError("DecodeVal", err)
+			return
+		}
+		ptr.word = unsafe.Pointer(&copied[headerSize])
+		return
+	}
+	root := iter.buf[headerSize : headerSize+decoder.Type().Size()]
+	iter.self = iter.allocate(decoder.onHeap, decoder.valType, 1, root)
 	ptr.word = unsafe.Pointer(&iter.self[0])
-	iter.cursor = iter.buf[8:]
+	iter.cursor = iter.buf[headerSize:]
 	decoder.decoder.Decode(iter)
 }
 
+// decodeRelocated copies the frame decoded in place by someone else, which must be left as it is.
+// The pointers are moved into the copy by the relocation table, or turned back into relative offsets
+// in a scratch copy, which is then decoded as the portable frame.
+func (decoder *rootDecoderWithCopy) decodeRelocated(ptr *emptyInterface, iter *Iterator) {
+	frame := iter.buf[:iter.NextSize()]
+	if frameHasRelocationTable(frame) {
+		copied := iter.allocator.Allocate(iter.objectSeq, frame)
+		if err := moveFrame(copied, frame); err != nil {
+			iter.ReportError("DecodeVal", err)
+			return
+		}
+		ptr.word = unsafe.Pointer(&copied[frameExtendedHeaderSize])
+		return
+	}
+	buf := iter.buf
+	defer func() {
+		iter.buf = buf
+		iter.origin = nil
+	}()
+	scratch := append([]byte(nil), frame...)
+	iter.origin = frame
+	iter.buf = scratch
+	iter.cursor = scratch[frameHeaderSize:]
+	decoder.decoder.Freeze(iter)
+	if iter.Error != nil {
+		return
+	}
+	iter.origin = nil
+	switchFrameState(scratch, decoder.signature, frameStateRelocated, frameStatePortable)
+	decoder.DecodeEmptyInterface(ptr, iter)
+}
+
+func (decoder *rootDecoderWithCopy) Freeze(iter *Iterator) {
+	freezeRoot(decoder.decoder, decoder.signature, iter)
+}
+
 type rootDecoderWithoutCopy struct {
 	valType   reflect.Type
 	signature uint32
@@ -62,8 +135,90 @@ func (decoder *rootDecoderWithoutCopy) Type() reflect.Type {
 }
 
 func (decoder *rootDecoderWithoutCopy) DecodeEmptyInterface(ptr *emptyInterface, iter *Iterator) {
-	ptr.word = unsafe.Pointer(&iter.buf[8])
-	iter.self = iter.buf[8:]
-	iter.cursor = iter.buf[8:]
-	decoder.decoder.Decode(iter)
+	headerSize := frameHeaderSizeOf(iter.buf)
+	ptr.word = unsafe.Pointer(&iter.buf[headerSize])
+	if !decoder.decoder.HasPointer() {
+		return
+	}
+	signature := decoder.signature
+	switch err := switchFrameState(iter.buf, signature, frameStatePortable, frameStateDecoding); err {
+	case nil:
+	case errFrameRelocated:
+		// decoded by previous Unmarshal, the value is ready to use
+		return
+	default:
+		iter.ReportError("DecodeVal", err)
+		return
+	}
+	frame := iter.buf
+	// the state is restored even if decoding panics, so that the frame is never left decoding
+	state := frameStateBroken
+	defer func() {
+		switchFrameState(frame, signature, frameStateDecoding, state)
+	}()
+	if frameHasRelocationTable(frame) {
+		if err := relocateFrame(frame); err != nil {
+			// the table is validated before any change, the frame is still portable
+			state = frameStatePortable
+			iter.ReportError("DecodeVal", err)
+			return
+		}
+	} else {
+		iter.self = frame[headerSize:]
+		iter.cursor = frame[headerSize:]
+		decoder.decoder.Decode(iter)
+		if iter.Error != nil {
+			// some pointers might have been written, they can not be told from the rest
+			return
+		}
+	}
+	state = frameStateRelocated
+}
+
+func (decoder *rootDecoderWithoutCopy) Freeze(iter *Iterator) {
+	freezeRoot(decoder.decoder, decoder.signature, iter)
+}
+
+// freezeRoot reverses the in place decoding, it is the same no matter the root value has been copied or not
+func freezeRoot(decoder ValDecoder, signature uint32, iter *Iterator) {
+	if !decoder.HasPointer() {
+		return
+	}
+	buf := iter.buf
+	frame := buf[:iter.NextSize()]
+	if err := switchFrameState(frame, signature, frameStateRelocated, frameStateDecoding); err != nil {
+		iter.ReportError("Freeze", err)
+		return
+	}
+	frozen := false
+	defer func() {
+		iter.buf = buf
+		iter.origin = nil
+		if frozen {
+			switchFrameState(frame, signature, frameStateDecoding, frameStatePortable)
+		} else {
+			// nothing has been written, the frame is still relocated
+			switchFrameState(frame, signature, frameStateDecoding, frameStateRelocated)
+		}
+	}()
+	if frameHasRelocationTable(frame) {
+		if err := freezeFrame(frame); err != nil {
+			iter.ReportError("Freeze", err)
+			return
+		}
+		frozen = true
+		return
+	}
+	// frozen in a scratch copy first, the frame is only written if every pointer can be frozen
+	headerSize := frameHeaderSizeOf(frame)
+	scratch := append([]byte(nil), frame...)
+	iter.origin = frame
+	iter.buf = scratch
+	iter.cursor = scratch[headerSize:]
+	decoder.Freeze(iter)
+	if iter.Error != nil {
+		return
+	}
+	copy(frame[headerSize:], scratch[headerSize:])
+	frozen = true
 }
@@ -9,3 +9,11 @@ type singlePointerFix struct {
 func (encoder *singlePointerFix) EncodeEmptyInterface(ptr unsafe.Pointer, stream *Stream) {
 	encoder.rootEncoder.EncodeEmptyInterface(unsafe.Pointer(&ptr), stream)
 }
+
+func (encoder *singlePointerFix) measureEmptyInterface(ptr unsafe.Pointer, sizer *frameSizer) {
+	encoder.rootEncoder.measureEmptyInterface(unsafe.Pointer(&ptr), sizer)
+}
+
+func (encoder *singlePointerFix) emitEmptyInterface(ptr unsafe.Pointer, emitter *frameEmitter) error {
+	return encoder.rootEncoder.emitEmptyInterface(unsafe.Pointer(&ptr), emitter)
+}
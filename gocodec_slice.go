@@ -2,37 +2,103 @@ package gocodec
 
 import "unsafe"
 
+// empty but not nil slice has nothing to point to, it is encoded with this Data instead,
+// and decoded to point to emptySliceBase, so that it does not become nil
+const emptySliceData = uintptr(1)
+
+var emptySliceBase struct{}
+
 type sliceEncoder struct {
 	BaseCodec
 	elemSize    int
+	elemAlign   int
 	elemEncoder ValEncoder
 }
 
 func (encoder *sliceEncoder) Encode(prSlice unsafe.Pointer, stream *Stream) {
 	rHeader := (*sliceReadonlyHeader)(prSlice)
+	pwSlice := unsafe.Pointer(&stream.buf[stream.cursor])
+	wHeader := (*sliceWritableHeader)(pwSlice)
 	if rHeader.Len == 0 {
+		switch {
+		case uintptr(rHeader.Data) == 0:
+			*wHeader = sliceWritableHeader{}
+		case stream.cfg.relocationTable:
+			// the relocation table is not aware of the sentinel, point to whatever comes next instead
+			*wHeader = sliceWritableHeader{}
+			stream.writeRelOffset()
+		default:
+			*wHeader = sliceWritableHeader{Data: emptySliceData}
+		}
 		return
 	}
-	pwSlice := unsafe.Pointer(&stream.buf[stream.cursor])
-	wHeader := (*sliceWritableHeader)(pwSlice)
 	wHeader.Cap = rHeader.Len
 	byteSlice := ptrAsBytes(encoder.elemSize*rHeader.Len, rHeader.Data)
 	// replace actual pointer with relative offset
-	wHeader.Data = uintptr(len(stream.buf)) - stream.cursor
+	stream.align(uintptr(encoder.elemAlign))
+	stream.writeRelOffset()
 	stream.cursor = uintptr(len(stream.buf)) // start of the bytes
 	stream.buf = append(stream.buf, byteSlice...)
 	if encoder.elemEncoder != nil {
 		endCursor := uintptr(len(stream.buf)) // end of the bytes
 		cursor := stream.cursor
-		prElem := uintptr(rHeader.Data)
-		for ; cursor < endCursor; cursor += uintptr(encoder.elemSize) {
+		for i := uintptr(0); cursor < endCursor; cursor += uintptr(encoder.elemSize) {
 			stream.cursor = cursor
-			encoder.elemEncoder.Encode(unsafe.Pointer(prElem), stream)
-			prElem += uintptr(encoder.elemSize)
+			encoder.elemEncoder.Encode(unsafe.Pointer(uintptr(rHeader.Data)+i*uintptr(encoder.elemSize)), stream)
+			i++
+		}
+	}
+}
+
+func (encoder *sliceEncoder) measure(prSlice unsafe.Pointer, sizer *frameSizer) {
+	rHeader := (*sliceReadonlyHeader)(prSlice)
+	if rHeader.Len == 0 {
+		if uintptr(rHeader.Data) != 0 {
+			// the offset of empty slice is relocated in the frame with relocation table
+			sizer.addOutOfLine(0, 1)
+		}
+		return
+	}
+	sizer.addOutOfLine(encoder.elemSize*rHeader.Len, encoder.elemAlign)
+	if encoder.elemEncoder != nil {
+		for i := 0; i < rHeader.Len; i++ {
+			sizer.measure(encoder.elemEncoder, unsafe.Pointer(uintptr(rHeader.Data)+uintptr(i*encoder.elemSize)))
 		}
 	}
 }
 
+func (encoder *sliceEncoder) layout(prSlice unsafe.Pointer, emitter *frameEmitter, at emitterWord) {
+	rHeader := (*sliceReadonlyHeader)(prSlice)
+	if rHeader.Len == 0 {
+		if uintptr(rHeader.Data) != 0 {
+			// the offset of empty slice is relocated in the frame with relocation table
+			emitter.relocate(at)
+		}
+		return
+	}
+	emitter.layoutValues(encoder.elemEncoder, rHeader.Data,
+		uintptr(encoder.elemSize), uintptr(encoder.elemAlign), rHeader.Len, at)
+}
+
+func (encoder *sliceEncoder) emit(prSlice unsafe.Pointer, emitter *frameEmitter, at uintptr) {
+	rHeader := (*sliceReadonlyHeader)(prSlice)
+	wHeader := (*sliceWritableHeader)(unsafe.Pointer(&emitter.self[at]))
+	if rHeader.Len == 0 {
+		switch {
+		case uintptr(rHeader.Data) == 0:
+			*wHeader = sliceWritableHeader{}
+		case emitter.cfg.relocationTable:
+			// points to whatever comes next, as Stream does
+			*wHeader = sliceWritableHeader{Data: emitter.positionOf(emitter.next) - (emitter.pos + at)}
+		default:
+			*wHeader = sliceWritableHeader{Data: emptySliceData}
+		}
+		return
+	}
+	wHeader.Cap = rHeader.Len
+	emitter.emitValues(encoder.elemEncoder, rHeader.Data, uintptr(encoder.elemSize), rHeader.Len, at)
+}
+
 type sliceDecoderWithoutCopy struct {
 	BaseCodec
 	elemSize    int
@@ -43,10 +109,13 @@ func (decoder *sliceDecoderWithoutCopy) Decode(iter *Iterator) {
 	pwSlice := unsafe.Pointer(&iter.self[0])
 	header := (*sliceWritableHeader)(pwSlice)
 	if header.Len == 0 {
+		if header.Data == emptySliceData {
+			iter.setPointer(0, unsafe.Pointer(&emptySliceBase))
+		}
 		return
 	}
 	relOffset := header.Data
-	header.Data = uintptr(unsafe.Pointer(&iter.cursor[relOffset]))
+	iter.setPointer(0, unsafe.Pointer(&iter.cursor[relOffset]))
 	if decoder.elemDecoder != nil {
 		cursor := iter.cursor[relOffset:]
 		for i := 0; i < header.Len; i++ {
@@ -60,6 +129,31 @@ func (decoder *sliceDecoderWithoutCopy) Decode(iter *Iterator) {
 	}
 }
 
+func (decoder *sliceDecoderWithoutCopy) Freeze(iter *Iterator) {
+	header := (*sliceWritableHeader)(unsafe.Pointer(&iter.cursor[0]))
+	if header.Len == 0 {
+		if header.Data == uintptr(unsafe.Pointer(&emptySliceBase)) {
+			header.Data = emptySliceData
+		}
+		return
+	}
+	relOffset := iter.relOffsetOf(header.Data)
+	if relOffset == 0 {
+		return
+	}
+	header.Data = relOffset
+	if decoder.elemDecoder != nil {
+		cursor := iter.cursor[relOffset:]
+		for i := 0; i < header.Len; i++ {
+			if i > 0 {
+				cursor = cursor[decoder.elemSize:]
+			}
+			iter.cursor = cursor
+			decoder.elemDecoder.Freeze(iter)
+		}
+	}
+}
+
 func (decoder *sliceDecoderWithoutCopy) HasPointer() bool {
 	return true
 }
@@ -68,18 +162,22 @@ type sliceDecoderWithCopy struct {
 	BaseCodec
 	elemSize    int
 	elemDecoder ValDecoder
+	onHeap      bool
 }
 
 func (decoder *sliceDecoderWithCopy) Decode(iter *Iterator) {
 	pwSlice := unsafe.Pointer(&iter.self[0])
 	header := (*sliceWritableHeader)(pwSlice)
 	if header.Len == 0 {
+		if header.Data == emptySliceData {
+			iter.setPointer(0, unsafe.Pointer(&emptySliceBase))
+		}
 		return
 	}
 	relOffset := header.Data
 	cursor := iter.cursor[relOffset:]
-	copied := iter.allocator.Allocate(iter.objectSeq, cursor[:decoder.elemSize*header.Len])
-	header.Data = uintptr(unsafe.Pointer(&copied[0]))
+	copied := iter.allocate(decoder.onHeap, decoder.valType.Elem(), header.Len, cursor[:decoder.elemSize*header.Len])
+	iter.setPointer(0, unsafe.Pointer(&copied[0]))
 	for i := 0; i < header.Len; i++ {
 		if i > 0 {
 			cursor = cursor[decoder.elemSize:]
@@ -91,6 +189,31 @@ func (decoder *sliceDecoderWithCopy) Decode(iter *Iterator) {
 	}
 }
 
+func (decoder *sliceDecoderWithCopy) Freeze(iter *Iterator) {
+	header := (*sliceWritableHeader)(unsafe.Pointer(&iter.cursor[0]))
+	if header.Len == 0 {
+		if header.Data == uintptr(unsafe.Pointer(&emptySliceBase)) {
+			header.Data = emptySliceData
+		}
+		return
+	}
+	relOffset := iter.relOffsetOf(header.Data)
+	if relOffset == 0 {
+		return
+	}
+	header.Data = relOffset
+	if decoder.elemDecoder != nil {
+		cursor := iter.cursor[relOffset:]
+		for i := 0; i < header.Len; i++ {
+			if i > 0 {
+				cursor = cursor[decoder.elemSize:]
+			}
+			iter.cursor = cursor
+			decoder.elemDecoder.Freeze(iter)
+		}
+	}
+}
+
 func (decoder *sliceDecoderWithCopy) HasPointer() bool {
 	return true
 }
@@ -7,21 +7,56 @@ type stringCodec struct {
 }
 
 func (codec *stringCodec) Encode(prStr unsafe.Pointer, stream *Stream) {
-	pwStr := unsafe.Pointer(&stream.buf[stream.cursor])
 	str := *(*string)(prStr)
-	offset := uintptr(len(stream.buf)) - stream.cursor
-	header := (*stringWritableHeader)(pwStr)
-	header.Data = offset
+	if len(str) == 0 {
+		// nothing out of line, Data is zeroed instead of pointing to the original
+		*(*uintptr)(unsafe.Pointer(&stream.buf[stream.cursor])) = 0
+		return
+	}
+	stream.writeRelOffset()
 	stream.buf = append(stream.buf, str...)
 }
 
+func (codec *stringCodec) measure(prStr unsafe.Pointer, sizer *frameSizer) {
+	if len(*(*string)(prStr)) == 0 {
+		return
+	}
+	sizer.addOutOfLine(len(*(*string)(prStr)), 1)
+}
+
+func (codec *stringCodec) layout(prStr unsafe.Pointer, emitter *frameEmitter, at emitterWord) {
+	header := (*stringReadonlyHeader)(prStr)
+	if header.Len == 0 {
+		return
+	}
+	emitter.layoutValues(nil, header.Data, 1, 1, header.Len, at)
+}
+
+func (codec *stringCodec) emit(prStr unsafe.Pointer, emitter *frameEmitter, at uintptr) {
+	header := (*stringReadonlyHeader)(prStr)
+	if header.Len == 0 {
+		emitter.setWord(at, 0)
+		return
+	}
+	emitter.emitValues(nil, header.Data, 1, header.Len, at)
+}
+
 func (codec *stringCodec) Decode(iter *Iterator) {
 	prStr := unsafe.Pointer(&iter.cursor[0])
 	header := (*stringWritableHeader)(prStr)
+	if header.Len == 0 {
+		return
+	}
 	relOffset := header.Data
-	pwStr := unsafe.Pointer(&iter.self[0])
-	header = (*stringWritableHeader)(pwStr)
-	header.Data = uintptr(unsafe.Pointer(&iter.cursor[relOffset]))
+	iter.setPointer(0, unsafe.Pointer(&iter.cursor[relOffset]))
+}
+
+func (codec *stringCodec) Freeze(iter *Iterator) {
+	header := (*stringWritableHeader)(unsafe.Pointer(&iter.cursor[0]))
+	if header.Len == 0 {
+		return
+	}
+	header.Data = iter.relOffsetOf(header.Data)
 }
 
 func (codec *stringCodec) HasPointer() bool {
@@ -1,23 +1,104 @@
 package gocodec
 
-import "unsafe"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// the field tagged with gocodec:"-" is zeroed when encoding, and left zero when decoding.
+// the field tagged with gocodec:"copy" is copied onto heap when decoding, so that it can outlive the buffer,
+// the value holding it is decoded onto heap as well, instead of in place.
+type fieldTag int
+
+const (
+	fieldTagNone fieldTag = iota
+	fieldTagSkip
+	fieldTagCopy
+)
+
+const (
+	skippedFieldSignature = uint32(0x736b6970) // "skip"
+	copiedFieldSignature  = uint32(0x636f7079) // "copy"
+)
+
+var errCopyWithRelocationTable = errors.New("gocodec:\"copy\" can not be used with relocation table")
+
+func fieldTagOf(field reflect.StructField) (fieldTag, error) {
+	switch tag := field.Tag.Get("gocodec"); tag {
+	case "":
+		return fieldTagNone, nil
+	case "-":
+		return fieldTagSkip, nil
+	case "copy":
+		return fieldTagCopy, nil
+	default:
+		return fieldTagNone, fmt.Errorf("unknown gocodec tag %q", tag)
+	}
+}
+
+// fieldIsSkipped tells the frame inspecting tools to leave the field out, as it is always zero
+func fieldIsSkipped(field reflect.StructField) bool {
+	return field.Tag.Get("gocodec") == "-"
+}
 
 type structEncoder struct {
 	BaseCodec
 	fields []structFieldEncoder
+	// the fields not tagged with gocodec:"-" in order, the noop ones included, for the tools reading frames
+	members []structFieldEncoder
+	// padding between and after the fields, only zeroed in canonical mode
+	gaps []structGap
+}
+
+type structGap struct {
+	offset uintptr
+	size   uintptr
 }
 
 type structFieldEncoder struct {
+	name    string
 	offset  uintptr
 	encoder ValEncoder
 }
 
 func (encoder *structEncoder) Encode(prStruct unsafe.Pointer, stream *Stream) {
 	baseCursor := stream.cursor
-	prBase := uintptr(prStruct)
+	for _, gap := range encoder.gaps {
+		padding := stream.buf[baseCursor+gap.offset : baseCursor+gap.offset+gap.size]
+		for i := range padding {
+			padding[i] = 0
+		}
+	}
 	for _, field := range encoder.fields {
 		stream.cursor = baseCursor + field.offset
-		field.encoder.Encode(unsafe.Pointer(prBase + field.offset), stream)
+		field.encoder.Encode(unsafe.Pointer(uintptr(prStruct)+field.offset), stream)
+	}
+}
+
+func (encoder *structEncoder) measure(prStruct unsafe.Pointer, sizer *frameSizer) {
+	for _, field := range encoder.fields {
+		sizer.measure(field.encoder, unsafe.Pointer(uintptr(prStruct)+field.offset))
+	}
+}
+
+func (encoder *structEncoder) layout(prStruct unsafe.Pointer, emitter *frameEmitter, at emitterWord) {
+	for _, field := range encoder.fields {
+		emitter.layoutValue(field.encoder, unsafe.Pointer(uintptr(prStruct)+field.offset),
+			emitterWord{at.block, at.offset + field.offset})
+	}
+}
+
+func (encoder *structEncoder) emit(prStruct unsafe.Pointer, emitter *frameEmitter, at uintptr) {
+	for _, gap := range encoder.gaps {
+		padding := emitter.self[at+gap.offset : at+gap.offset+gap.size]
+		for i := range padding {
+			padding[i] = 0
+		}
+	}
+	for _, field := range encoder.fields {
+		emitter.emitValue(field.encoder, unsafe.Pointer(uintptr(prStruct)+field.offset), at+field.offset)
 	}
 }
 
@@ -39,6 +120,9 @@ func (decoder *structDecoderWithoutPointer) Decode(iter *Iterator) {
 	}
 }
 
+func (decoder *structDecoderWithoutPointer) Freeze(iter *Iterator) {
+}
+
 type structDecoderWithPointer struct {
 	BaseCodec
 	fields []structFieldDecoder
@@ -54,6 +138,103 @@ func (decoder *structDecoderWithPointer) Decode(iter *Iterator) {
 	}
 }
 
+func (decoder *structDecoderWithPointer) Freeze(iter *Iterator) {
+	baseCursor := iter.cursor
+	for _, field := range decoder.fields {
+		iter.cursor = baseCursor[field.offset:]
+		field.decoder.Freeze(iter)
+	}
+}
+
 func (decoder *structDecoderWithPointer) HasPointer() bool {
 	return true
 }
+
+type skippedFieldEncoder struct {
+	BaseCodec
+}
+
+func (encoder *skippedFieldEncoder) Encode(ptr unsafe.Pointer, stream *Stream) {
+	self := stream.buf[stream.cursor : stream.cursor+encoder.valType.Size()]
+	for i := range self {
+		self[i] = 0
+	}
+}
+
+func (encoder *skippedFieldEncoder) measure(ptr unsafe.Pointer, sizer *frameSizer) {
+}
+
+func (encoder *skippedFieldEncoder) layout(ptr unsafe.Pointer, emitter *frameEmitter, at emitterWord) {
+}
+
+func (encoder *skippedFieldEncoder) emit(ptr unsafe.Pointer, emitter *frameEmitter, at uintptr) {
+	self := emitter.self[at : at+encoder.valType.Size()]
+	for i := range self {
+		self[i] = 0
+	}
+}
+
+// copiedFieldDecoder decodes the field, then moves everything it references onto heap.
+// The field itself is already on heap, as the value holding it is decoded onto heap.
+type copiedFieldDecoder struct {
+	BaseCodec
+	decoder ValDecoder
+}
+
+func (decoder *copiedFieldDecoder) Decode(iter *Iterator) {
+	self := iter.self
+	decoder.decoder.Decode(iter)
+	if iter.Error != nil {
+		return
+	}
+	copyOntoHeap(decoder.valType, unsafe.Pointer(&self[0]))
+}
+
+// Freeze is not supported, as the field no longer points into the frame
+func (decoder *copiedFieldDecoder) Freeze(iter *Iterator) {
+	iter.ReportError("Freeze", fmt.Errorf("can not freeze %s copied onto heap", decoder.valType.String()))
+}
+
+func (decoder *copiedFieldDecoder) HasPointer() bool {
+	return true
+}
+
+// copyOntoHeap replaces every string, slice and pointer within the value at ptr with a copy allocated by go
+func copyOntoHeap(valType reflect.Type, ptr unsafe.Pointer) {
+	switch valType.Kind() {
+	case reflect.String:
+		str := (*string)(ptr)
+		*str = string(append([]byte(nil), *str...))
+	case reflect.Struct:
+		for i := 0; i < valType.NumField(); i++ {
+			field := valType.Field(i)
+			copyOntoHeap(field.Type, unsafe.Pointer(uintptr(ptr)+field.Offset))
+		}
+	case reflect.Array:
+		elemType := valType.Elem()
+		for i := 0; i < valType.Len(); i++ {
+			copyOntoHeap(elemType, unsafe.Pointer(uintptr(ptr)+uintptr(i)*elemType.Size()))
+		}
+	case reflect.Slice:
+		val := reflect.NewAt(valType, ptr).Elem()
+		if val.IsNil() {
+			return
+		}
+		copied := reflect.MakeSlice(valType, val.Len(), val.Len())
+		reflect.Copy(copied, val)
+		elemType := valType.Elem()
+		for i := 0; i < val.Len(); i++ {
+			copyOntoHeap(elemType, unsafe.Pointer(copied.Index(i).UnsafeAddr()))
+		}
+		val.Set(copied)
+	case reflect.Ptr:
+		val := reflect.NewAt(valType, ptr).Elem()
+		if val.IsNil() {
+			return
+		}
+		copied := reflect.New(valType.Elem())
+		copied.Elem().Set(val.Elem())
+		copyOntoHeap(valType.Elem(), unsafe.Pointer(copied.Pointer()))
+		val.Set(copied)
+	}
+}
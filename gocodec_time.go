@@ -0,0 +1,193 @@
+package gocodec
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// time.Time is encoded as
+// seconds since unix epoch (8 bytes) + nanoseconds (8 bytes) + zone word (8 bytes)
+// the zone word is 0 for UTC, 1 for Local, otherwise the relative offset to the out of line zone:
+// utc offset in seconds (4 bytes) + name length (4 bytes) + IANA name
+// the location is looked up by name when decoding, falls back to fixed zone if not found,
+// or if its offset at the instant is not the encoded one
+var timeType = reflect.TypeOf(time.Time{})
+
+const timeSignature = uint32(0x74696d65) // "time"
+
+const (
+	timeZoneUTC   = 0
+	timeZoneLocal = 1
+	// timeZoneNamed is never written, the zone word of named zone is the relative offset instead
+	timeZoneNamed = 2
+)
+
+type encodedTime struct {
+	sec  int64
+	nsec int64
+	zone uintptr
+}
+
+type encodedTimeZone struct {
+	offset  int32
+	nameLen uint32
+}
+
+var errTimeWithRelocationTable = errors.New("time.Time can not be encoded with relocation table")
+
+// timeZoneCache holds the locations loaded by name, the names that do not resolve are never cached,
+// as they are read from the frames and can be anything
+var timeZoneCache = &sync.Map{}
+
+// loadTimeZone returns the named location, if it has the same offset at the instant as the one encoded,
+// otherwise the encoded zone is a fixed zone, or named after a location it does not follow
+func loadTimeZone(name string, offset int32, sec int64) *time.Location {
+	if loc := loadLocation(name); loc != nil {
+		if _, locOffset := time.Unix(sec, 0).In(loc).Zone(); int32(locOffset) == offset {
+			return loc
+		}
+	}
+	return time.FixedZone(name, int(offset))
+}
+
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+	loc, found := timeZoneCache.Load(name)
+	if found {
+		return loc.(*time.Location)
+	}
+	newLoc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	timeZoneCache.Store(name, newLoc)
+	return newLoc
+}
+
+// timeOf rebuilds time.Time from the portable form, zoneBytes starts where the zone word points to
+func timeOf(encoded *encodedTime, zoneBytes func(size uintptr) []byte) (time.Time, error) {
+	switch encoded.zone {
+	case timeZoneUTC:
+		return time.Unix(encoded.sec, encoded.nsec).UTC(), nil
+	case timeZoneLocal:
+		return time.Unix(encoded.sec, encoded.nsec).Local(), nil
+	}
+	header := zoneBytes(unsafe.Sizeof(encodedTimeZone{}))
+	if header == nil {
+		return time.Time{}, errPointerOutOfFrame
+	}
+	zone := (*encodedTimeZone)(unsafe.Pointer(&header[0]))
+	block := zoneBytes(unsafe.Sizeof(encodedTimeZone{}) + uintptr(zone.nameLen))
+	if block == nil {
+		return time.Time{}, errPointerOutOfFrame
+	}
+	name := string(block[unsafe.Sizeof(encodedTimeZone{}):])
+	return time.Unix(encoded.sec, encoded.nsec).In(loadTimeZone(name, zone.offset, encoded.sec)), nil
+}
+
+type timeCodec struct {
+	BaseCodec
+}
+
+func (codec *timeCodec) Encode(ptr unsafe.Pointer, stream *Stream) {
+	val := *(*time.Time)(ptr)
+	encoded := (*encodedTime)(unsafe.Pointer(&stream.buf[stream.cursor]))
+	encoded.sec = val.Unix()
+	encoded.nsec = int64(val.Nanosecond())
+	switch val.Location() {
+	case time.UTC:
+		encoded.zone = timeZoneUTC
+	case time.Local:
+		encoded.zone = timeZoneLocal
+	default:
+		_, offset := val.Zone()
+		stream.cursor += unsafe.Offsetof(encoded.zone)
+		writeTimeZone(stream, val.Location().String(), int32(offset))
+	}
+}
+
+// writeTimeZone appends the named zone, the zone word is at stream.cursor
+func writeTimeZone(stream *Stream, name string, offset int32) {
+	zone := encodedTimeZone{offset: offset, nameLen: uint32(len(name))}
+	stream.align(unsafe.Alignof(zone))
+	stream.writeRelOffset()
+	stream.buf = append(stream.buf, ptrAsBytes(int(unsafe.Sizeof(zone)), unsafe.Pointer(&zone))...)
+	stream.buf = append(stream.buf, name...)
+}
+
+func (codec *timeCodec) measure(ptr unsafe.Pointer, sizer *frameSizer) {
+	val := *(*time.Time)(ptr)
+	switch val.Location() {
+	case time.UTC, time.Local:
+	default:
+		sizer.addOutOfLine(int(unsafe.Sizeof(encodedTimeZone{}))+len(val.Location().String()),
+			int(unsafe.Alignof(encodedTimeZone{})))
+	}
+}
+
+func (codec *timeCodec) layout(ptr unsafe.Pointer, emitter *frameEmitter, at emitterWord) {
+	val := *(*time.Time)(ptr)
+	switch val.Location() {
+	case time.UTC, time.Local:
+		return
+	}
+	name := val.Location().String()
+	_, offset := val.Zone()
+	zone := encodedTimeZone{offset: int32(offset), nameLen: uint32(len(name))}
+	data := append([]byte(nil), ptrAsBytes(int(unsafe.Sizeof(zone)), unsafe.Pointer(&zone))...)
+	emitter.relocate(emitterWord{at.block, at.offset + unsafe.Offsetof(encodedTime{}.zone)})
+	emitter.addBlock(emitterBlock{size: uintptr(len(data) + len(name)), align: unsafe.Alignof(zone),
+		data: append(data, name...)})
+}
+
+func (codec *timeCodec) emit(ptr unsafe.Pointer, emitter *frameEmitter, at uintptr) {
+	val := *(*time.Time)(ptr)
+	encoded := (*encodedTime)(unsafe.Pointer(&emitter.self[at]))
+	encoded.sec = val.Unix()
+	encoded.nsec = int64(val.Nanosecond())
+	switch val.Location() {
+	case time.UTC:
+		encoded.zone = timeZoneUTC
+	case time.Local:
+		encoded.zone = timeZoneLocal
+	default:
+		emitter.emitData(at + unsafe.Offsetof(encoded.zone))
+	}
+}
+
+func (codec *timeCodec) Decode(iter *Iterator) {
+	encoded := *(*encodedTime)(unsafe.Pointer(&iter.cursor[0]))
+	zoneCursor := iter.cursor[unsafe.Offsetof(encoded.zone):]
+	val, err := timeOf(&encoded, func(size uintptr) []byte {
+		return zoneCursor[encoded.zone : encoded.zone+size]
+	})
+	if err != nil {
+		iter.ReportError("DecodeTime", err)
+		return
+	}
+	*(*time.Time)(unsafe.Pointer(&iter.self[0])) = val
+}
+
+// Freeze only works for UTC and Local, as where the zone name was is lost after decoding
+func (codec *timeCodec) Freeze(iter *Iterator) {
+	val := *(*time.Time)(unsafe.Pointer(&iter.cursor[0]))
+	encoded := (*encodedTime)(unsafe.Pointer(&iter.cursor[0]))
+	switch val.Location() {
+	case time.UTC:
+		*encoded = encodedTime{val.Unix(), int64(val.Nanosecond()), timeZoneUTC}
+	case time.Local:
+		*encoded = encodedTime{val.Unix(), int64(val.Nanosecond()), timeZoneLocal}
+	default:
+		iter.ReportError("Freeze", fmt.Errorf("can not freeze time.Time in location %s", val.Location()))
+	}
+}
+
+func (codec *timeCodec) HasPointer() bool {
+	return true
+}
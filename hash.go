@@ -0,0 +1,56 @@
+package gocodec
+
+import (
+	"hash"
+	"io"
+)
+
+func Hash(h hash.Hash, val interface{}) ([]byte, error) {
+	return DefaultConfig.Hash(h, val)
+}
+
+func HashFrame(h hash.Hash, buf []byte, candidatePointer interface{}) ([]byte, error) {
+	return DefaultConfig.HashFrame(h, buf, candidatePointer)
+}
+
+// Hash streams the canonical encoding of val into h without holding it in memory, and returns h.Sum(nil).
+// The frame hashed has no relocation table, so that the digest does not depend on Config other than the codecs.
+func (cfg *frozenConfig) Hash(h hash.Hash, val interface{}) ([]byte, error) {
+	encoder := cfg.hashConfig.borrowEncoder(h)
+	err := encoder.Encode(val)
+	cfg.hashConfig.returnEncoder(encoder)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// HashFrame returns the same digest as Hash of the value encoded in the first frame of buf.
+// The frame is decoded without being changed, as ReadonlyConfig does,
+// so it does not need to be canonical, it can also be already decoded in place.
+func (cfg *frozenConfig) HashFrame(h hash.Hash, buf []byte, candidatePointer interface{}) ([]byte, error) {
+	ptr, err := cfg.hashConfig.Unmarshal(buf, candidatePointer)
+	if err != nil {
+		return nil, err
+	}
+	encoder := cfg.hashConfig.borrowEncoder(h)
+	err = encoder.EncodePointer(ptr)
+	cfg.hashConfig.returnEncoder(encoder)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// borrowEncoder takes an Encoder writing to w from the pool, it must be returned once done
+func (cfg *frozenConfig) borrowEncoder(w io.Writer) *Encoder {
+	encoder := cfg.hashEncoders.Get().(*Encoder)
+	encoder.emitter.writer.reset(w)
+	return encoder
+}
+
+func (cfg *frozenConfig) returnEncoder(encoder *Encoder) {
+	encoder.err = nil
+	encoder.emitter.release()
+	cfg.hashEncoders.Put(encoder)
+}
@@ -0,0 +1,494 @@
+package gocodec
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// frameReader follows the pointer words of a frame without decoding it,
+// the frame can be either portable or already decoded in place.
+type frameReader struct {
+	frame     []byte
+	relocated bool
+	// where the root value starts
+	root      uintptr
+	signature uint32
+}
+
+// newFrameReader reads the frame holding the value of the signature
+func newFrameReader(buf []byte, signature uint32) (*frameReader, error) {
+	if err := checkFrame(buf); err != nil {
+		return nil, err
+	}
+	state, ok := frameState(buf, signature)
+	if !ok {
+		return nil, errSignatureMismatch
+	}
+	if state == frameStateBroken {
+		return nil, errFrameBroken
+	}
+	frame := buf[:frameSize(buf)]
+	if frameHasRelocationTable(frame) {
+		frame = frame[:len(frame)-4-4*len(relocationTableOf(frame))]
+	}
+	return &frameReader{frame: frame, relocated: state != frameStatePortable,
+		root: frameHeaderSizeOf(frame), signature: signature}, nil
+}
+
+func (reader *frameReader) ptr(pos uintptr) unsafe.Pointer {
+	return unsafe.Pointer(&reader.frame[pos])
+}
+
+func (reader *frameReader) word(pos uintptr) uintptr {
+	return *(*uintptr)(reader.ptr(pos))
+}
+
+// deref returns the frame position the pointer word at pos points to, 0 means nil
+func (reader *frameReader) deref(pos uintptr, size uintptr) (uintptr, error) {
+	ptr := reader.word(pos)
+	if ptr == 0 {
+		return 0, nil
+	}
+	target := pos + ptr
+	if reader.relocated {
+		target = ptr - uintptr(unsafe.Pointer(&reader.frame[0]))
+	}
+	if target <= pos || target+size > uintptr(len(reader.frame)) {
+		return 0, fmt.Errorf("pointer at %d does not point into the frame", pos)
+	}
+	return target, nil
+}
+
+// value reads a value of type valType at pos, valType must not have pointer
+func (reader *frameReader) value(pos uintptr, valType reflect.Type) interface{} {
+	if valType.Size() == 0 {
+		return reflect.Zero(valType).Interface()
+	}
+	return reflect.NewAt(valType, reader.ptr(pos)).Elem().Interface()
+}
+
+func (reader *frameReader) string(pos uintptr) (string, uintptr, error) {
+	header := (*stringWritableHeader)(reader.ptr(pos))
+	if header.Len == 0 {
+		return "", 0, nil
+	}
+	target, err := reader.deref(pos, uintptr(header.Len))
+	if err != nil {
+		return "", 0, err
+	}
+	return string(reader.frame[target : target+uintptr(header.Len)]), target, nil
+}
+
+// frameValue is a value inside a frame, followed through the encoder tree that wrote it.
+// It is the one traversal shared by the tools reading frames without decoding them,
+// Dump, Diff, ToJSON, FromJSON and UnmarshalInto switch on its kind instead of the reflect kind.
+type frameValue struct {
+	reader  *frameReader
+	pos     uintptr
+	valType reflect.Type
+	// encoder is nil for the elements left as they are, such as the elements of []int
+	encoder ValEncoder
+}
+
+type frameValueKind int
+
+const (
+	frameKindScalar frameValueKind = iota
+	frameKindString
+	frameKindStruct
+	frameKindArray
+	frameKindSlice
+	frameKindPointer
+	frameKindTime
+	frameKindBlob
+	frameKindCustom
+)
+
+// rootFrameValue is the root value of the first frame in buf, which must be written for valType
+func (cfg *frozenConfig) rootFrameValue(buf []byte, valType reflect.Type) (frameValue, error) {
+	encoder, err := encoderOfType(cfg, valType)
+	if err != nil {
+		return frameValue{}, err
+	}
+	reader, err := newFrameReader(buf, encoder.Signature())
+	if err == errSignatureMismatch {
+		return frameValue{}, fmt.Errorf("%s does not match the signature", valType.String())
+	}
+	if err != nil {
+		return frameValue{}, err
+	}
+	return frameValue{reader: reader, pos: reader.root, valType: valType, encoder: valEncoderOf(encoder)}, nil
+}
+
+func (value frameValue) kind() frameValueKind {
+	return frameKindOf(value.valType, value.encoder)
+}
+
+// frameKindOf tells how the value written by the encoder is laid out in the frame
+func frameKindOf(valType reflect.Type, encoder ValEncoder) frameValueKind {
+	switch encoder.(type) {
+	case nil, *NoopCodec:
+		if valType.Kind() == reflect.Array {
+			return frameKindArray
+		}
+		return frameKindScalar
+	case *stringCodec:
+		return frameKindString
+	case *structEncoder:
+		return frameKindStruct
+	case *arrayEncoder:
+		return frameKindArray
+	case *sliceEncoder:
+		return frameKindSlice
+	case *pointerEncoder:
+		return frameKindPointer
+	case *timeCodec:
+		return frameKindTime
+	case *binaryMarshalerCodec:
+		return frameKindBlob
+	}
+	return frameKindCustom
+}
+
+// bytes returns the value as it is laid out in the frame
+func (value frameValue) bytes() []byte {
+	return value.reader.frame[value.pos : value.pos+value.valType.Size()]
+}
+
+// scalar returns the bool, number or complex value
+func (value frameValue) scalar() interface{} {
+	return value.reader.value(value.pos, value.valType)
+}
+
+func (value frameValue) string() (string, uintptr, error) {
+	return value.reader.string(value.pos)
+}
+
+// fields returns the fields of struct in order to be followed by field,
+// the fields tagged with gocodec:"-" are left out
+func (value frameValue) fields() []structFieldEncoder {
+	return value.encoder.(*structEncoder).members
+}
+
+func (value frameValue) field(field structFieldEncoder) frameValue {
+	return frameValue{reader: value.reader, pos: value.pos + field.offset, valType: field.encoder.Type(),
+		encoder: field.encoder}
+}
+
+// padding returns the bytes between and after the fields of struct, one after another
+func (value frameValue) padding() []byte {
+	var padding []byte
+	for _, gap := range structGapsOf(value.valType) {
+		padding = append(padding, value.reader.frame[value.pos+gap.offset:value.pos+gap.offset+gap.size]...)
+	}
+	return padding
+}
+
+// frameElements are the elements of array or slice, laid out one after another from pos
+type frameElements struct {
+	reader      *frameReader
+	pos         uintptr
+	length      int
+	elemType    reflect.Type
+	elemEncoder ValEncoder
+}
+
+func (elements frameElements) at(index int) frameValue {
+	return frameValue{reader: elements.reader, pos: elements.pos + uintptr(index)*elements.elemType.Size(),
+		valType: elements.elemType, encoder: elements.elemEncoder}
+}
+
+func (elements frameElements) size() uintptr {
+	return uintptr(elements.length) * elements.elemType.Size()
+}
+
+// bytes returns the elements as they are laid out in the frame
+func (elements frameElements) bytes() []byte {
+	return elements.reader.frame[elements.pos : elements.pos+elements.size()]
+}
+
+// raw tells if the elements are written as their memory, such as []int and [4]struct{ X, Y int }
+func (elements frameElements) raw() bool {
+	return elements.elemEncoder == nil || encoderIsRaw(elements.elemEncoder)
+}
+
+func (value frameValue) array() frameElements {
+	var elemEncoder ValEncoder
+	if encoder, isArray := value.encoder.(*arrayEncoder); isArray {
+		elemEncoder = encoder.elemEncoder
+	}
+	return frameElements{reader: value.reader, pos: value.pos, length: value.valType.Len(),
+		elemType: value.valType.Elem(), elemEncoder: elemEncoder}
+}
+
+// slice returns the elements of slice, isNil tells nil slice apart from empty one
+func (value frameValue) slice() (elements frameElements, isNil bool, err error) {
+	elements = frameElements{reader: value.reader, elemType: value.valType.Elem(),
+		elemEncoder: value.encoder.(*sliceEncoder).elemEncoder}
+	header := (*sliceWritableHeader)(value.reader.ptr(value.pos))
+	if header.Len == 0 {
+		return elements, header.Data == 0, nil
+	}
+	target, err := value.reader.deref(value.pos, uintptr(header.Len)*elements.elemType.Size())
+	if err != nil {
+		return elements, false, err
+	}
+	elements.pos = target
+	elements.length = header.Len
+	return elements, false, nil
+}
+
+// deref returns the value the pointer points to
+func (value frameValue) deref() (elem frameValue, isNil bool, err error) {
+	elemType := value.valType.Elem()
+	target, err := value.reader.deref(value.pos, elemType.Size())
+	if err != nil || target == 0 {
+		return frameValue{}, err == nil, err
+	}
+	return frameValue{reader: value.reader, pos: target, valType: elemType,
+		encoder: value.encoder.(*pointerEncoder).elemEncoder}, false, nil
+}
+
+// frameTime is time.Time as it is in the frame, the named zone is kept as name and offset instead of being looked up
+type frameTime struct {
+	sec    int64
+	nsec   int64
+	zone   uintptr // timeZoneUTC, timeZoneLocal or timeZoneNamed
+	name   string
+	offset int32
+}
+
+func (value frameValue) time() (frameTime, error) {
+	if value.reader.relocated {
+		val := *(*time.Time)(value.reader.ptr(value.pos))
+		decoded := frameTime{sec: val.Unix(), nsec: int64(val.Nanosecond())}
+		switch val.Location() {
+		case time.UTC:
+			decoded.zone = timeZoneUTC
+		case time.Local:
+			decoded.zone = timeZoneLocal
+		default:
+			_, offset := val.Zone()
+			decoded.zone = timeZoneNamed
+			decoded.name = val.Location().String()
+			decoded.offset = int32(offset)
+		}
+		return decoded, nil
+	}
+	encoded := (*encodedTime)(value.reader.ptr(value.pos))
+	decoded := frameTime{sec: encoded.sec, nsec: encoded.nsec, zone: encoded.zone}
+	if encoded.zone == timeZoneUTC || encoded.zone == timeZoneLocal {
+		return decoded, nil
+	}
+	zoneSize := unsafe.Sizeof(encodedTimeZone{})
+	target, err := value.reader.deref(value.pos+unsafe.Offsetof(encoded.zone), zoneSize)
+	if err != nil {
+		return frameTime{}, err
+	}
+	zone := (*encodedTimeZone)(value.reader.ptr(target))
+	if uintptr(zone.nameLen) > uintptr(len(value.reader.frame))-target-zoneSize {
+		return frameTime{}, errPointerOutOfFrame
+	}
+	decoded.zone = timeZoneNamed
+	decoded.name = string(value.reader.frame[target+zoneSize : target+zoneSize+uintptr(zone.nameLen)])
+	decoded.offset = zone.offset
+	return decoded, nil
+}
+
+// toTime looks up the named zone the same way as decoding does
+func (decoded frameTime) toTime() time.Time {
+	switch decoded.zone {
+	case timeZoneUTC:
+		return time.Unix(decoded.sec, decoded.nsec).UTC()
+	case timeZoneLocal:
+		return time.Unix(decoded.sec, decoded.nsec).Local()
+	}
+	return time.Unix(decoded.sec, decoded.nsec).In(loadTimeZone(decoded.name, decoded.offset, decoded.sec))
+}
+
+// blob returns the bytes returned by MarshalBinary and where they are in the frame.
+// The value decoded in place is marshaled again, as the offset to the blob is overwritten.
+func (value frameValue) blob() ([]byte, uintptr, error) {
+	if value.reader.relocated {
+		data, err := value.encoder.(*binaryMarshalerCodec).marshal(value.reader.ptr(value.pos))
+		return data, 0, err
+	}
+	target, err := value.reader.deref(value.pos, 8)
+	if err != nil {
+		return nil, 0, err
+	}
+	length := *(*uint64)(value.reader.ptr(target))
+	if length > uint64(uintptr(len(value.reader.frame))-target-8) {
+		return nil, 0, errPointerOutOfFrame
+	}
+	return value.reader.frame[target+8 : target+8+uintptr(length)], target, nil
+}
+
+func customCodecError(path string, valType reflect.Type) error {
+	if path == "" {
+		path = "."
+	}
+	return fmt.Errorf("%s: %s has custom codec, the frame can not be inspected", path, valType.String())
+}
+
+func Dump(w io.Writer, buf []byte, candidatePointer interface{}) error {
+	return DefaultConfig.Dump(w, buf, candidatePointer)
+}
+
+// Dump writes the annotated layout of the first frame in buf, one line per value.
+// The offsets are relative to the start of the frame, out of line values are marked as such.
+func (cfg *frozenConfig) Dump(w io.Writer, buf []byte, candidatePointer interface{}) error {
+	root, err := cfg.rootFrameValue(buf, reflect.TypeOf(candidatePointer).Elem())
+	if err != nil {
+		return err
+	}
+	dumper := &frameDumper{w: w}
+	fmt.Fprintf(w, "0x%04x header size %d signature 0x%08x\n", 0, frameSize(buf), root.reader.signature)
+	dumper.dump(root, "")
+	return dumper.err
+}
+
+const dumpElementsLimit = 8
+
+type frameDumper struct {
+	w   io.Writer
+	err error
+}
+
+func (dumper *frameDumper) line(pos uintptr, path string, valType reflect.Type, format string, args ...interface{}) {
+	if path == "" {
+		path = "."
+	}
+	fmt.Fprintf(dumper.w, "0x%04x %s %s = %s\n", pos, path, valType.String(), fmt.Sprintf(format, args...))
+}
+
+func (dumper *frameDumper) outOfLine(pos uintptr, size uintptr, path string) {
+	if path == "" {
+		path = "."
+	}
+	fmt.Fprintf(dumper.w, "0x%04x [out of line %d bytes] %s\n", pos, size, path)
+}
+
+func (dumper *frameDumper) dump(value frameValue, path string) {
+	if dumper.err != nil {
+		return
+	}
+	switch value.kind() {
+	case frameKindScalar:
+		dumper.line(value.pos, path, value.valType, "%v", value.scalar())
+	case frameKindString:
+		str, target, err := value.string()
+		if err != nil {
+			dumper.err = err
+			return
+		}
+		if target == 0 {
+			dumper.line(value.pos, path, value.valType, "%q", str)
+			return
+		}
+		dumper.line(value.pos, path, value.valType, "%q -> 0x%04x", str, target)
+		dumper.outOfLine(target, uintptr(len(str)), path)
+	case frameKindStruct:
+		for _, field := range value.fields() {
+			dumper.dump(value.field(field), joinFieldPath(path, field.name))
+		}
+	case frameKindArray:
+		dumper.elements(value.array(), path, value.valType)
+	case frameKindSlice:
+		elements, isNil, err := value.slice()
+		if err != nil {
+			dumper.err = err
+			return
+		}
+		if isNil {
+			dumper.line(value.pos, path, value.valType, "nil")
+			return
+		}
+		if elements.length == 0 {
+			dumper.line(value.pos, path, value.valType, "len 0")
+			return
+		}
+		dumper.line(value.pos, path, value.valType, "len %d -> 0x%04x", elements.length, elements.pos)
+		dumper.outOfLine(elements.pos, elements.size(), path)
+		dumper.elements(elements, path, value.valType)
+	case frameKindPointer:
+		elem, isNil, err := value.deref()
+		if err != nil {
+			dumper.err = err
+			return
+		}
+		if isNil {
+			dumper.line(value.pos, path, value.valType, "nil")
+			return
+		}
+		dumper.line(value.pos, path, value.valType, "-> 0x%04x", elem.pos)
+		dumper.outOfLine(elem.pos, elem.valType.Size(), path)
+		dumper.dump(elem, path)
+	case frameKindTime:
+		val, err := value.time()
+		if err != nil {
+			dumper.err = err
+			return
+		}
+		dumper.line(value.pos, path, value.valType, "%s", val.toTime())
+	case frameKindBlob:
+		data, target, err := value.blob()
+		if err != nil {
+			dumper.err = err
+			return
+		}
+		if target == 0 {
+			dumper.line(value.pos, path, value.valType, "blob %x", data)
+			return
+		}
+		dumper.line(value.pos, path, value.valType, "blob %x -> 0x%04x", data, target)
+		dumper.outOfLine(target, uintptr(8+len(data)), path)
+	default:
+		dumper.err = customCodecError(path, value.valType)
+	}
+}
+
+// elements prints the first few elements written as their memory in one line
+func (dumper *frameDumper) elements(elements frameElements, path string, valType reflect.Type) {
+	if !elements.raw() {
+		for i := 0; i < elements.length; i++ {
+			dumper.dump(elements.at(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+		return
+	}
+	values := make([]interface{}, 0, dumpElementsLimit)
+	for i := 0; i < elements.length && i < dumpElementsLimit; i++ {
+		values = append(values, elements.at(i).scalar())
+	}
+	if elements.length > dumpElementsLimit {
+		dumper.line(elements.pos, path, valType, "%v ... (%d more)", values, elements.length-dumpElementsLimit)
+		return
+	}
+	dumper.line(elements.pos, path, valType, "%v", values)
+}
+
+func joinFieldPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func typeHasPointer(valType reflect.Type) bool {
+	switch valType.Kind() {
+	case reflect.String, reflect.Slice, reflect.Ptr:
+		return true
+	case reflect.Array:
+		return valType.Len() > 0 && typeHasPointer(valType.Elem())
+	case reflect.Struct:
+		for i := 0; i < valType.NumField(); i++ {
+			if !fieldIsSkipped(valType.Field(i)) && typeHasPointer(valType.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
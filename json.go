@@ -0,0 +1,532 @@
+package gocodec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+func ToJSON(buf []byte, candidatePointer interface{}) ([]byte, error) {
+	return DefaultConfig.ToJSON(buf, candidatePointer)
+}
+
+func FromJSON(data []byte, candidatePointer interface{}) ([]byte, error) {
+	return DefaultConfig.FromJSON(data, candidatePointer)
+}
+
+// jsonPaddingKey holds the padding of struct as hex, it is only written when the padding is not all zero
+const jsonPaddingKey = "[padding]"
+
+// jsonTimeWithOffsetSeconds keeps the seconds of utc offset, which RFC 3339 leaves out
+const jsonTimeWithOffsetSeconds = "2006-01-02T15:04:05.999999999-07:00:00"
+
+// ToJSON converts the first frame in buf to json, reading the frame directly without decoding it.
+// Unexported fields are included, []byte and the blob of BinaryMarshaler are written as base64 string,
+// complex number as [real, imag], time.Time as RFC 3339 string followed by the zone name in brackets
+// unless it is UTC, such as "2017-07-14T03:40:00+01:00[Europe/London]". The padding of struct is written
+// under the key "[padding]" when it is not zero, so that FromJSON gives back the same bytes.
+func (cfg *frozenConfig) ToJSON(buf []byte, candidatePointer interface{}) ([]byte, error) {
+	root, err := cfg.rootFrameValue(buf, reflect.TypeOf(candidatePointer).Elem())
+	if err != nil {
+		return nil, err
+	}
+	writer := &jsonWriter{}
+	writer.write(root, "")
+	if writer.err != nil {
+		return nil, writer.err
+	}
+	return writer.buf.Bytes(), nil
+}
+
+// FromJSON is the reverse of ToJSON, the json is written into a frame through the same encoders as Marshal,
+// the frame is byte-identical to the one ToJSON read from, as long as the strings are valid UTF-8.
+// Time without the zone in brackets is taken as UTC.
+func (cfg *frozenConfig) FromJSON(data []byte, candidatePointer interface{}) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var node interface{}
+	if err := decoder.Decode(&node); err != nil {
+		return nil, err
+	}
+	valType := reflect.TypeOf(candidatePointer).Elem()
+	encoder, err := encoderOfType(cfg, valType)
+	if err != nil {
+		return nil, err
+	}
+	stream := cfg.NewStream(nil)
+	stream.beginFrame()
+	stream.zeros(valType.Size())
+	reader := &jsonReader{stream: stream}
+	if err := reader.read(node, headerSizeOf(cfg.relocationTable), valType, valEncoderOf(encoder), ""); err != nil {
+		return nil, err
+	}
+	stream.endFrame(encoder.Signature())
+	if stream.Error != nil {
+		return nil, stream.Error
+	}
+	return stream.buf, nil
+}
+
+type jsonWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (writer *jsonWriter) write(value frameValue, path string) {
+	if writer.err != nil {
+		return
+	}
+	switch value.kind() {
+	case frameKindScalar:
+		writer.scalar(reflect.ValueOf(value.scalar()))
+	case frameKindString:
+		str, _, err := value.string()
+		if err != nil {
+			writer.err = err
+			return
+		}
+		writer.string(str)
+	case frameKindStruct:
+		writer.buf.WriteByte('{')
+		for i, field := range value.fields() {
+			if i > 0 {
+				writer.buf.WriteByte(',')
+			}
+			writer.string(field.name)
+			writer.buf.WriteByte(':')
+			writer.write(value.field(field), joinFieldPath(path, field.name))
+		}
+		if padding := value.padding(); !isZeros(padding) {
+			if len(value.fields()) > 0 {
+				writer.buf.WriteByte(',')
+			}
+			writer.string(jsonPaddingKey)
+			writer.buf.WriteByte(':')
+			writer.string(hex.EncodeToString(padding))
+		}
+		writer.buf.WriteByte('}')
+	case frameKindArray:
+		writer.elements(value.array(), path)
+	case frameKindSlice:
+		elements, isNil, err := value.slice()
+		if err != nil {
+			writer.err = err
+			return
+		}
+		if isNil {
+			writer.buf.WriteString("null")
+			return
+		}
+		if elements.elemType.Kind() == reflect.Uint8 {
+			writer.string(base64.StdEncoding.EncodeToString(elements.bytes()))
+			return
+		}
+		writer.elements(elements, path)
+	case frameKindPointer:
+		elem, isNil, err := value.deref()
+		if err != nil {
+			writer.err = err
+			return
+		}
+		if isNil {
+			writer.buf.WriteString("null")
+			return
+		}
+		writer.write(elem, path)
+	case frameKindTime:
+		val, err := value.time()
+		if err != nil {
+			writer.err = err
+			return
+		}
+		writer.string(formatJSONTime(val))
+	case frameKindBlob:
+		data, _, err := value.blob()
+		if err != nil {
+			writer.err = err
+			return
+		}
+		writer.string(base64.StdEncoding.EncodeToString(data))
+	default:
+		writer.err = customCodecError(path, value.valType)
+	}
+}
+
+func (writer *jsonWriter) elements(elements frameElements, path string) {
+	writer.buf.WriteByte('[')
+	for i := 0; i < elements.length; i++ {
+		if i > 0 {
+			writer.buf.WriteByte(',')
+		}
+		writer.write(elements.at(i), fmt.Sprintf("%s[%d]", path, i))
+	}
+	writer.buf.WriteByte(']')
+}
+
+// scalar writes by kind, so that the String method of named type is not called
+func (writer *jsonWriter) scalar(val reflect.Value) {
+	switch val.Kind() {
+	case reflect.Bool:
+		writer.buf.WriteString(strconv.FormatBool(val.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writer.buf.WriteString(strconv.FormatInt(val.Int(), 10))
+	case reflect.Float32, reflect.Float64:
+		writer.float(val.Float(), val.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		writer.buf.WriteByte('[')
+		writer.float(real(val.Complex()), val.Type().Bits()/2)
+		writer.buf.WriteByte(',')
+		writer.float(imag(val.Complex()), val.Type().Bits()/2)
+		writer.buf.WriteByte(']')
+	default:
+		writer.buf.WriteString(strconv.FormatUint(val.Uint(), 10))
+	}
+}
+
+// float writes NaN and infinity as string, as json number can not represent them
+func (writer *jsonWriter) float(val float64, bits int) {
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		writer.string(strconv.FormatFloat(val, 'g', -1, bits))
+		return
+	}
+	writer.buf.WriteString(strconv.FormatFloat(val, 'g', -1, bits))
+}
+
+func (writer *jsonWriter) string(str string) {
+	encoded, _ := json.Marshal(str)
+	writer.buf.Write(encoded)
+}
+
+func formatJSONTime(val frameTime) string {
+	switch val.zone {
+	case timeZoneUTC:
+		return time.Unix(val.sec, val.nsec).UTC().Format(time.RFC3339Nano)
+	case timeZoneLocal:
+		return time.Unix(val.sec, val.nsec).Local().Format(time.RFC3339Nano) + "[Local]"
+	}
+	layout := time.RFC3339Nano
+	if val.offset%60 != 0 {
+		layout = jsonTimeWithOffsetSeconds
+	}
+	zone := time.FixedZone(val.name, int(val.offset))
+	return time.Unix(val.sec, val.nsec).In(zone).Format(layout) + "[" + val.name + "]"
+}
+
+// parseJSONTime is the reverse of formatJSONTime, the zone named Local is taken as time.Local
+func parseJSONTime(str string) (frameTime, error) {
+	parsed := frameTime{zone: timeZoneUTC}
+	if open := strings.IndexByte(str, '['); open != -1 && strings.HasSuffix(str, "]") {
+		parsed.zone = timeZoneNamed
+		parsed.name = str[open+1 : len(str)-1]
+		if parsed.name == "Local" {
+			parsed.zone = timeZoneLocal
+			parsed.name = ""
+		}
+		str = str[:open]
+	}
+	val, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		val, err = time.Parse(jsonTimeWithOffsetSeconds, str)
+	}
+	if err != nil {
+		return frameTime{}, err
+	}
+	parsed.sec = val.Unix()
+	parsed.nsec = int64(val.Nanosecond())
+	if parsed.zone == timeZoneNamed {
+		_, offset := val.Zone()
+		parsed.offset = int32(offset)
+	}
+	return parsed, nil
+}
+
+// jsonReader writes the json into the frame being written by the stream,
+// the values are laid out the same way as the encoders lay them out
+type jsonReader struct {
+	stream *Stream
+}
+
+// read writes the json node as the value at the buffer position pos, which is already zeroed
+func (reader *jsonReader) read(node interface{}, pos uintptr, valType reflect.Type, encoder ValEncoder, path string) error {
+	stream := reader.stream
+	kind := frameKindOf(valType, encoder)
+	if node == nil {
+		if kind == frameKindSlice || kind == frameKindPointer {
+			return nil
+		}
+		return fmt.Errorf("%s: %s can not be null", path, valType.String())
+	}
+	switch kind {
+	case frameKindScalar:
+		return readJSONScalar(reflect.NewAt(valType, unsafe.Pointer(&stream.buf[pos])).Elem(), node, path)
+	case frameKindString:
+		str, isString := node.(string)
+		if !isString {
+			return jsonTypeError(path, valType, node)
+		}
+		if len(str) == 0 {
+			return nil
+		}
+		(*stringWritableHeader)(unsafe.Pointer(&stream.buf[pos])).Len = len(str)
+		stream.cursor = pos
+		stream.writeRelOffset()
+		stream.buf = append(stream.buf, str...)
+	case frameKindStruct:
+		return reader.fields(node, pos, valType, encoder.(*structEncoder), path)
+	case frameKindArray:
+		nodes, isArray := node.([]interface{})
+		if !isArray || len(nodes) != valType.Len() {
+			return jsonTypeError(path, valType, node)
+		}
+		var elemEncoder ValEncoder
+		if encoder, isArray := encoder.(*arrayEncoder); isArray {
+			elemEncoder = encoder.elemEncoder
+		}
+		return reader.elements(nodes, pos, valType.Elem(), elemEncoder, path)
+	case frameKindSlice:
+		return reader.slice(node, pos, valType, encoder.(*sliceEncoder), path)
+	case frameKindPointer:
+		elemType := valType.Elem()
+		stream.align(uintptr(elemType.Align()))
+		stream.cursor = pos
+		stream.writeRelOffset()
+		elemPos := uintptr(len(stream.buf))
+		stream.zeros(elemType.Size())
+		return reader.read(node, elemPos, elemType, encoder.(*pointerEncoder).elemEncoder, path)
+	case frameKindTime:
+		str, isString := node.(string)
+		if !isString {
+			return jsonTypeError(path, valType, node)
+		}
+		val, err := parseJSONTime(str)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		encoded := (*encodedTime)(unsafe.Pointer(&stream.buf[pos]))
+		*encoded = encodedTime{sec: val.sec, nsec: val.nsec, zone: val.zone}
+		if val.zone == timeZoneNamed {
+			stream.cursor = pos + unsafe.Offsetof(encoded.zone)
+			writeTimeZone(stream, val.name, val.offset)
+		}
+	case frameKindBlob:
+		str, isString := node.(string)
+		if !isString {
+			return jsonTypeError(path, valType, node)
+		}
+		data, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		stream.cursor = pos
+		writeBlob(stream, data)
+	default:
+		if path == "" {
+			path = "."
+		}
+		return fmt.Errorf("%s: %s has custom codec, it can not be read from json", path, valType.String())
+	}
+	return nil
+}
+
+func (reader *jsonReader) fields(node interface{}, pos uintptr, valType reflect.Type, encoder *structEncoder,
+	path string) error {
+	nodes, isObject := node.(map[string]interface{})
+	if !isObject {
+		return jsonTypeError(path, valType, node)
+	}
+	// the names are matched with the members encoded, FieldByName would match the promoted fields as well
+	for name := range nodes {
+		if name != jsonPaddingKey && !encoder.hasMember(name) {
+			return fmt.Errorf("%s: %s has no field %s", path, valType.String(), name)
+		}
+	}
+	for _, member := range encoder.members {
+		fieldNode, hasField := nodes[member.name]
+		if !hasField {
+			continue
+		}
+		err := reader.read(fieldNode, pos+member.offset, member.encoder.Type(), member.encoder,
+			joinFieldPath(path, member.name))
+		if err != nil {
+			return err
+		}
+	}
+	if paddingNode, hasPadding := nodes[jsonPaddingKey]; hasPadding {
+		return reader.padding(paddingNode, pos, valType, path)
+	}
+	return nil
+}
+
+func (encoder *structEncoder) hasMember(name string) bool {
+	for _, member := range encoder.members {
+		if member.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (reader *jsonReader) padding(node interface{}, pos uintptr, valType reflect.Type, path string) error {
+	str, isString := node.(string)
+	if !isString {
+		return fmt.Errorf("%s: padding must be hex string, but found %v", path, node)
+	}
+	padding, err := hex.DecodeString(str)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	gaps := structGapsOf(valType)
+	size := uintptr(0)
+	for _, gap := range gaps {
+		size += gap.size
+	}
+	if uintptr(len(padding)) != size {
+		return fmt.Errorf("%s: %s has %d bytes of padding, but found %d", path, valType.String(), size, len(padding))
+	}
+	for _, gap := range gaps {
+		copy(reader.stream.buf[pos+gap.offset:pos+gap.offset+gap.size], padding)
+		padding = padding[gap.size:]
+	}
+	return nil
+}
+
+func (reader *jsonReader) slice(node interface{}, pos uintptr, valType reflect.Type, encoder *sliceEncoder,
+	path string) error {
+	stream := reader.stream
+	var data []byte
+	str, isString := node.(string)
+	nodes, isArray := node.([]interface{})
+	switch {
+	case isString && valType.Elem().Kind() == reflect.Uint8:
+		decoded, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		data = decoded
+		nodes = make([]interface{}, len(decoded))
+	case !isArray:
+		return jsonTypeError(path, valType, node)
+	}
+	header := (*sliceWritableHeader)(unsafe.Pointer(&stream.buf[pos]))
+	if len(nodes) == 0 {
+		if stream.cfg.relocationTable {
+			// the same as sliceEncoder, the relocation table is not aware of the sentinel
+			stream.cursor = pos
+			stream.writeRelOffset()
+			return nil
+		}
+		header.Data = emptySliceData
+		return nil
+	}
+	header.Len = len(nodes)
+	header.Cap = len(nodes)
+	stream.align(uintptr(encoder.elemAlign))
+	stream.cursor = pos
+	stream.writeRelOffset()
+	elemPos := uintptr(len(stream.buf))
+	if data != nil {
+		stream.buf = append(stream.buf, data...)
+		return nil
+	}
+	stream.zeros(uintptr(len(nodes) * encoder.elemSize))
+	return reader.elements(nodes, elemPos, valType.Elem(), encoder.elemEncoder, path)
+}
+
+func (reader *jsonReader) elements(nodes []interface{}, pos uintptr, elemType reflect.Type, elemEncoder ValEncoder,
+	path string) error {
+	for i, node := range nodes {
+		err := reader.read(node, pos+uintptr(i)*elemType.Size(), elemType, elemEncoder, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readJSONScalar sets the bool, number or complex value from the json node, val must be addressable
+func readJSONScalar(val reflect.Value, node interface{}, path string) error {
+	valType := val.Type()
+	switch valType.Kind() {
+	case reflect.Bool:
+		boolVal, isBool := node.(bool)
+		if !isBool {
+			return jsonTypeError(path, valType, node)
+		}
+		val.SetBool(boolVal)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, isNumber := node.(json.Number)
+		if !isNumber {
+			return jsonTypeError(path, valType, node)
+		}
+		intVal, err := strconv.ParseInt(string(number), 10, valType.Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		val.SetInt(intVal)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		number, isNumber := node.(json.Number)
+		if !isNumber {
+			return jsonTypeError(path, valType, node)
+		}
+		uintVal, err := strconv.ParseUint(string(number), 10, valType.Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		val.SetUint(uintVal)
+	case reflect.Float32, reflect.Float64:
+		floatVal, err := readJSONFloat(node, valType.Bits(), path)
+		if err != nil {
+			return err
+		}
+		val.SetFloat(floatVal)
+	case reflect.Complex64, reflect.Complex128:
+		parts, isArray := node.([]interface{})
+		if !isArray || len(parts) != 2 {
+			return jsonTypeError(path, valType, node)
+		}
+		realVal, err := readJSONFloat(parts[0], valType.Bits()/2, path)
+		if err != nil {
+			return err
+		}
+		imagVal, err := readJSONFloat(parts[1], valType.Bits()/2, path)
+		if err != nil {
+			return err
+		}
+		val.SetComplex(complex(realVal, imagVal))
+	default:
+		return fmt.Errorf("%s: unsupported type %s", path, valType.String())
+	}
+	return nil
+}
+
+func readJSONFloat(node interface{}, bits int, path string) (float64, error) {
+	switch node := node.(type) {
+	case json.Number:
+		return strconv.ParseFloat(string(node), bits)
+	case string:
+		// NaN and infinity
+		return strconv.ParseFloat(node, bits)
+	}
+	return 0, fmt.Errorf("%s: expect number, but found %v", path, node)
+}
+
+func jsonTypeError(path string, valType reflect.Type, node interface{}) error {
+	return fmt.Errorf("%s: can not read %s from json %v", path, valType.String(), node)
+}
+
+func isZeros(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
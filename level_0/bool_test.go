@@ -0,0 +1,38 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_bool(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal(true)
+	should.Nil(err)
+	should.Equal([]byte{0x1, 0, 0, 0, 0, 0, 0, 0}, encoded[8:])
+	val, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*bool)(nil))
+	should.Nil(err)
+	should.Equal(true, *(val.(*bool)))
+	val, err = gocodec.Unmarshal(encoded, (*bool)(nil))
+	should.Nil(err)
+	should.Equal(true, *(val.(*bool)))
+}
+
+func Test_struct_of_bool(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 bool
+		Field2 bool
+	}
+	obj := TestObject{true, false}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	should.Equal([]byte{0x1, 0x0, 0, 0, 0, 0, 0, 0}, encoded[8:])
+	decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(obj, *decoded.(*TestObject))
+	decoded, err = gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(obj, *decoded.(*TestObject))
+}
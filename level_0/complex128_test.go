@@ -0,0 +1,23 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_complex128(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal(complex(100, -1))
+	should.Nil(err)
+	should.Equal([]byte{
+		0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x59, 0x40,
+		0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xf0, 0xbf,
+	}, encoded[8:])
+	val, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*complex128)(nil))
+	should.Nil(err)
+	should.Equal(complex(100, -1), *(val.(*complex128)))
+	val, err = gocodec.Unmarshal(encoded, (*complex128)(nil))
+	should.Nil(err)
+	should.Equal(complex(100, -1), *(val.(*complex128)))
+}
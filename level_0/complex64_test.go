@@ -0,0 +1,20 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_complex64(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal(complex64(complex(100, -1)))
+	should.Nil(err)
+	should.Equal([]byte{0x0, 0x0, 0xc8, 0x42, 0x0, 0x0, 0x80, 0xbf}, encoded[8:])
+	val, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*complex64)(nil))
+	should.Nil(err)
+	should.Equal(complex64(complex(100, -1)), *(val.(*complex64)))
+	val, err = gocodec.Unmarshal(encoded, (*complex64)(nil))
+	should.Nil(err)
+	should.Equal(complex64(complex(100, -1)), *(val.(*complex64)))
+}
@@ -11,7 +11,7 @@ func Test_int8(t *testing.T) {
 	should := require.New(t)
 	encoded, err := gocodec.Marshal(int8(100))
 	should.Nil(err)
-	should.Equal([]byte{100}, encoded[8:])
+	should.Equal([]byte{100, 0, 0, 0, 0, 0, 0, 0}, encoded[8:])
 	decoded, err := gocodec.Unmarshal(encoded, (*int8)(nil))
 	should.Nil(err)
 	should.Equal(int8(100), reflect.ValueOf(decoded).Elem().Interface())
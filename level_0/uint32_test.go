@@ -11,7 +11,7 @@ func Test_uint32(t *testing.T) {
 	should := require.New(t)
 	encoded, err := gocodec.Marshal(uint32(100))
 	should.Nil(err)
-	should.Equal([]byte{100, 0, 0, 0}, encoded[8:])
+	should.Equal([]byte{100, 0, 0, 0, 0, 0, 0, 0}, encoded[8:])
 	decoded, err := gocodec.Unmarshal(encoded, (*uint32)(nil))
 	should.Nil(err)
 	should.Equal(uint32(100), reflect.ValueOf(decoded).Elem().Interface())
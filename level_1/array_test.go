@@ -15,7 +15,7 @@ func Test_single_ptr_int_array(t *testing.T) {
 	should.Nil(err)
 	should.Equal([]byte{
 		0x8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
-		0x1,
+		0x1, 0, 0, 0, 0, 0, 0, 0, // padded to 8 bytes
 	}, encoded[8:])
 	decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*TestObject)(nil))
 	should.Nil(err)
@@ -36,7 +36,7 @@ func Test_two_ptrs_in_array(t *testing.T) {
 		0x10, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
 		0x9, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
 		1,
-		1,
+		1, 0, 0, 0, 0, 0, 0, // padded to 8 bytes
 	}, encoded[8:])
 	decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*TestObject)(nil))
 	should.Nil(err)
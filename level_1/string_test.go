@@ -10,7 +10,7 @@ func Test_string(t *testing.T) {
 	should := require.New(t)
 	encoded, err := gocodec.Marshal("hello")
 	should.Nil(err)
-	should.Equal([]byte{0x10, 0, 0, 0, 0, 0, 0, 0, 5, 0, 0, 0, 0, 0, 0, 0, 'h', 'e', 'l', 'l', 'o'}, encoded[8:])
+	should.Equal([]byte{0x10, 0, 0, 0, 0, 0, 0, 0, 5, 0, 0, 0, 0, 0, 0, 0, 'h', 'e', 'l', 'l', 'o', 0, 0, 0}, encoded[8:])
 	decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*string)(nil))
 	should.Nil(err)
 	should.Equal("hello", *decoded.(*string))
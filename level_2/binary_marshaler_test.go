@@ -0,0 +1,130 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"runtime"
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+type testPoint struct {
+	X int64
+	Y int64
+}
+
+// swapped to tell the marshaled bytes from the memory layout
+func (point *testPoint) MarshalBinary() ([]byte, error) {
+	if point.X < 0 {
+		return nil, errors.New("negative x")
+	}
+	return []byte{byte(point.Y), byte(point.X)}, nil
+}
+
+func (point *testPoint) UnmarshalBinary(data []byte) error {
+	point.X = int64(data[1])
+	point.Y = int64(data[0])
+	return nil
+}
+
+var binaryMarshalerConfig = gocodec.Config{BinaryMarshaler: true}.Froze()
+
+func Test_binary_marshaler(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Name  string
+		Point testPoint
+		Links []url.URL
+	}
+	link, err := url.Parse("http://example.com/a?b=c#d")
+	should.Nil(err)
+	obj := TestObject{"hello", testPoint{1, 2}, []url.URL{*link}}
+	encoded, err := binaryMarshalerConfig.Marshal(obj)
+	should.Nil(err)
+	size, err := binaryMarshalerConfig.EncodedSize(obj)
+	should.Nil(err)
+	should.Equal(len(encoded), size)
+	rawEncoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	_, err = binaryMarshalerConfig.Unmarshal(rawEncoded, (*TestObject)(nil))
+	should.NotNil(err)
+	decoded, err := gocodec.Config{BinaryMarshaler: true, ReadonlyDecode: true}.Froze().
+		Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(obj, *decoded.(*TestObject))
+	decoded, err = binaryMarshalerConfig.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	runtime.GC()
+	should.Equal(obj, *decoded.(*TestObject))
+	should.NotNil(binaryMarshalerConfig.Freeze(encoded, (*TestObject)(nil)))
+	buf := &bytes.Buffer{}
+	should.Nil(binaryMarshalerConfig.NewEncoder(buf).Encode(obj))
+	expected, err := binaryMarshalerConfig.Marshal(obj)
+	should.Nil(err)
+	should.Equal(expected, buf.Bytes())
+}
+
+// same size as testPoint, but its blob means something else
+type testRange struct {
+	From int64
+	To   int64
+}
+
+func (rng *testRange) MarshalBinary() ([]byte, error) {
+	return []byte{byte(rng.From), byte(rng.To)}, nil
+}
+
+func (rng *testRange) UnmarshalBinary(data []byte) error {
+	rng.From = int64(data[0])
+	rng.To = int64(data[1])
+	return nil
+}
+
+func Test_binary_marshaler_signature_has_type_name(t *testing.T) {
+	should := require.New(t)
+	encoded, err := binaryMarshalerConfig.Marshal(testPoint{1, 2})
+	should.Nil(err)
+	_, err = binaryMarshalerConfig.Unmarshal(encoded, (*testRange)(nil))
+	should.NotNil(err)
+	decoded, err := binaryMarshalerConfig.Unmarshal(encoded, (*testPoint)(nil))
+	should.Nil(err)
+	should.Equal(testPoint{1, 2}, *decoded.(*testPoint))
+}
+
+func Test_binary_marshaler_errors(t *testing.T) {
+	should := require.New(t)
+	_, err := binaryMarshalerConfig.Marshal(testPoint{-1, 2})
+	should.Contains(err.Error(), "negative x")
+	_, err = binaryMarshalerConfig.EncodedSize(testPoint{-1, 2})
+	should.Contains(err.Error(), "negative x")
+	err = binaryMarshalerConfig.NewEncoder(&bytes.Buffer{}).Encode(testPoint{-1, 2})
+	should.Contains(err.Error(), "negative x")
+	_, err = gocodec.Config{BinaryMarshaler: true, RelocationTable: true}.Froze().Marshal(testPoint{1, 2})
+	should.NotNil(err)
+	_, err = binaryMarshalerConfig.ToJSON(nil, (*testPoint)(nil))
+	should.NotNil(err)
+}
+
+func Test_binary_marshaler_diff(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Name  string
+		Point testPoint
+	}
+	a, err := binaryMarshalerConfig.Marshal(TestObject{"hello", testPoint{1, 2}})
+	should.Nil(err)
+	b, err := binaryMarshalerConfig.Marshal(TestObject{"hello", testPoint{1, 3}})
+	should.Nil(err)
+	output := bytes.NewBuffer(nil)
+	should.Nil(binaryMarshalerConfig.Dump(output, a, (*TestObject)(nil)))
+	should.Contains(output.String(), "0x0018 Point test.testPoint = blob 0201 -> 0x0030\n")
+	// the blob of the value decoded in place is marshaled again
+	_, err = binaryMarshalerConfig.Unmarshal(a, (*TestObject)(nil))
+	should.Nil(err)
+	differences, err := binaryMarshalerConfig.Diff(a, b, (*TestObject)(nil))
+	should.Nil(err)
+	should.Len(differences, 1)
+	should.Equal("Point: blob 0201 != blob 0301", differences[0].String())
+}
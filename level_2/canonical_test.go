@@ -0,0 +1,63 @@
+package test
+
+import (
+	"testing"
+	"unsafe"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_canonical_zeroes_padding(t *testing.T) {
+	should := require.New(t)
+	type SubObject struct {
+		Field1 int64
+		Field2 uint8
+	}
+	type TestObject struct {
+		Field1 uint8
+		Field2 int64
+		Field3 [2]SubObject
+		Field4 *SubObject
+	}
+	api := gocodec.Config{Canonical: true}.Froze()
+	clean := TestObject{Field1: 1, Field2: 2, Field4: &SubObject{3, 4}}
+	dirty := clean
+	dirty.Field4 = &SubObject{3, 4}
+	(*[8]byte)(unsafe.Pointer(&dirty))[1] = 0xff
+	(*[16]byte)(unsafe.Pointer(&dirty.Field3[1]))[9] = 0xff
+	(*[16]byte)(unsafe.Pointer(dirty.Field4))[15] = 0xff
+	encodedClean, err := api.Marshal(clean)
+	should.Nil(err)
+	encodedDirty, err := api.Marshal(dirty)
+	should.Nil(err)
+	should.Equal(encodedClean, encodedDirty)
+	decoded, err := api.Unmarshal(encodedDirty, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(clean, *decoded.(*TestObject))
+	encodedDirty, err = gocodec.Marshal(dirty)
+	should.Nil(err)
+	should.NotEqual(encodedClean, encodedDirty)
+}
+
+func Test_canonical_empty_slice(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 []int
+		Field2 []string
+	}
+	api := gocodec.Config{Canonical: true}.Froze()
+	encodedNil, err := api.Marshal(TestObject{})
+	should.Nil(err)
+	encodedEmpty, err := api.Marshal(TestObject{make([]int, 0, 10), []string{"a"}[:0]})
+	should.Nil(err)
+	should.NotEqual(encodedNil, encodedEmpty)
+	// the capacity and where the empty slice points to are not encoded
+	encodedOtherEmpty, err := api.Marshal(TestObject{[]int{}, []string{}})
+	should.Nil(err)
+	should.Equal(encodedEmpty, encodedOtherEmpty)
+	decoded, err := api.Unmarshal(encodedEmpty, (*TestObject)(nil))
+	should.Nil(err)
+	should.NotNil(decoded.(*TestObject).Field1)
+	should.Equal(0, len(decoded.(*TestObject).Field1))
+	should.NotNil(decoded.(*TestObject).Field2)
+}
@@ -0,0 +1,168 @@
+package test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"reflect"
+	"testing"
+	"unsafe"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+// cstring stands for third party type, which gocodec does not know how to follow,
+// the nul terminated bytes with the length kept aside
+type cstring struct {
+	p      *byte
+	length int
+}
+
+func newCString(str string) cstring {
+	data := append([]byte(str), 0)
+	return cstring{&data[0], len(str)}
+}
+
+func (str cstring) bytes() []byte {
+	if str.p == nil {
+		return nil
+	}
+	return (*[1 << 30]byte)(unsafe.Pointer(str.p))[:str.length+1:str.length+1]
+}
+
+type cstringCodec struct {
+	gocodec.BaseCodec
+}
+
+func (codec *cstringCodec) Encode(ptr unsafe.Pointer, stream *gocodec.Stream) {
+	if data := (*cstring)(ptr).bytes(); data != nil {
+		stream.WriteOutOfLine(0, data)
+	}
+}
+
+func (codec *cstringCodec) Decode(iter *gocodec.Iterator) {
+	if data := iter.OutOfLine(0, iter.Word(unsafe.Offsetof(cstring{}.length))+1); data != nil {
+		iter.SetPointer(0, data)
+	}
+}
+
+func (codec *cstringCodec) Freeze(iter *gocodec.Iterator) {
+	iter.FreezePointer(0)
+}
+
+func (codec *cstringCodec) HasPointer() bool {
+	return true
+}
+
+func cstringConfig(cfg gocodec.Config) gocodec.API {
+	codec := &cstringCodec{*gocodec.NewBaseCodec(reflect.TypeOf(cstring{}), 0x63737472)}
+	cfg.RegisterCodec(reflect.TypeOf(cstring{}), codec, codec)
+	return cfg.Froze()
+}
+
+func Test_custom_codec(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Id    int
+		Name  cstring
+		Alias cstring
+		Tags  []cstring
+	}
+	obj := TestObject{1, newCString("hello"), cstring{}, []cstring{newCString("a"), newCString("bc")}}
+	for _, api := range []gocodec.API{
+		cstringConfig(gocodec.Config{}),
+		cstringConfig(gocodec.Config{ReadonlyDecode: true}),
+		cstringConfig(gocodec.Config{RelocationTable: true}),
+	} {
+		encoded, err := api.Marshal(obj)
+		should.Nil(err)
+		size, err := api.EncodedSize(obj)
+		should.Nil(err)
+		should.Equal(len(encoded), size)
+		decoded, err := api.Unmarshal(encoded, (*TestObject)(nil))
+		should.Nil(err)
+		val := decoded.(*TestObject)
+		should.Equal(1, val.Id)
+		should.Equal("hello\x00", string(val.Name.bytes()))
+		should.Nil(val.Alias.p)
+		should.Equal("a\x00", string(val.Tags[0].bytes()))
+		should.Equal("bc\x00", string(val.Tags[1].bytes()))
+	}
+	api := cstringConfig(gocodec.Config{})
+	encoded, err := api.Marshal(obj)
+	should.Nil(err)
+	original := append([]byte(nil), encoded...)
+	_, err = api.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Nil(api.Freeze(encoded, (*TestObject)(nil)))
+	should.Equal(original, encoded)
+	canonical, err := cstringConfig(gocodec.Config{Canonical: true}).Marshal(obj)
+	should.Nil(err)
+	expected := sha256.Sum256(canonical)
+	digest, err := api.Hash(sha256.New(), obj)
+	should.Nil(err)
+	should.Equal(expected[:], digest)
+	digest, err = api.HashFrame(sha256.New(), encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(expected[:], digest)
+}
+
+func Test_custom_codec_encoder(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Id    int8
+		Name  cstring
+		Tags  []cstring
+		Alias *cstring
+	}
+	alias := newCString("world")
+	obj := TestObject{1, newCString("hello"), []cstring{newCString("a"), {}, newCString("bc")}, &alias}
+	for _, api := range []gocodec.API{
+		cstringConfig(gocodec.Config{}),
+		cstringConfig(gocodec.Config{RelocationTable: true}),
+	} {
+		expected, err := api.Marshal(obj)
+		should.Nil(err)
+		buf := &bytes.Buffer{}
+		should.Nil(api.NewEncoder(buf).Encode(obj))
+		should.Equal(expected, buf.Bytes())
+	}
+}
+
+func Test_config_with_codec_is_comparable(t *testing.T) {
+	should := require.New(t)
+	codec := &cstringCodec{*gocodec.NewBaseCodec(reflect.TypeOf(cstring{}), 0x63737472)}
+	cfg := gocodec.Config{}
+	cfg.RegisterCodec(reflect.TypeOf(cstring{}), codec, codec)
+	copied := cfg
+	should.True(copied == cfg)
+	copied.RegisterCodec(reflect.TypeOf(cstring{}), codec, codec)
+	should.False(copied == cfg)
+}
+
+// growingCodec writes one more word every time, as if the value was changed by someone else meanwhile
+type growingCodec struct {
+	gocodec.BaseCodec
+	calls int
+}
+
+func (codec *growingCodec) Encode(ptr unsafe.Pointer, stream *gocodec.Stream) {
+	codec.calls++
+	stream.WriteOutOfLine(0, make([]byte, 8*codec.calls))
+}
+
+func (codec *growingCodec) Freeze(iter *gocodec.Iterator) {
+}
+
+func Test_marshal_to_value_changed_size(t *testing.T) {
+	should := require.New(t)
+	type growing struct {
+		p *byte
+	}
+	codec := &growingCodec{BaseCodec: *gocodec.NewBaseCodec(reflect.TypeOf(growing{}), 0x67726f77)}
+	cfg := gocodec.Config{}
+	cfg.RegisterCodec(reflect.TypeOf(growing{}), codec, codec)
+	api := cfg.Froze()
+	dst := make([]byte, 100)
+	_, err := api.MarshalTo(dst, growing{})
+	should.NotNil(err)
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_unmarshal_twice(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 []string
+		Field2 *int
+	}
+	one := 1
+	obj := TestObject{[]string{"hello", "world"}, &one}
+	for _, api := range []gocodec.API{gocodec.DefaultConfig, gocodec.Config{RelocationTable: true}.Froze()} {
+		encoded, err := api.Marshal(obj)
+		should.Nil(err)
+		decoded1, err := api.Unmarshal(encoded, (*TestObject)(nil))
+		should.Nil(err)
+		decoded2, err := api.Unmarshal(encoded, (*TestObject)(nil))
+		should.Nil(err)
+		should.True(decoded1.(*TestObject) == decoded2.(*TestObject))
+		should.Equal(obj, *decoded2.(*TestObject))
+		decoded3, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*TestObject)(nil))
+		should.Nil(err)
+		should.Equal(obj, *decoded3.(*TestObject))
+		should.Nil(api.Freeze(encoded, (*TestObject)(nil)))
+		should.NotNil(api.Freeze(encoded, (*TestObject)(nil)))
+		decoded4, err := api.Unmarshal(encoded, (*TestObject)(nil))
+		should.Nil(err)
+		should.Equal(obj, *decoded4.(*TestObject))
+	}
+}
+
+func Test_relocate_twice(t *testing.T) {
+	should := require.New(t)
+	api := gocodec.Config{RelocationTable: true}.Froze()
+	encoded, err := api.Marshal([]string{"hello"})
+	should.Nil(err)
+	_, err = api.Relocate(encoded)
+	should.Nil(err)
+	_, err = api.Relocate(encoded)
+	should.Nil(err)
+	decoded, err := api.Unmarshal(encoded, (*[]string)(nil))
+	should.Nil(err)
+	should.Equal([]string{"hello"}, *decoded.(*[]string))
+}
+
+func Test_unmarshal_corrupted_frame(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 *int
+		Field2 *int
+	}
+	one := 1
+	encoded, err := gocodec.Marshal(TestObject{&one, &one})
+	should.Nil(err)
+	// Field2 points far beyond the frame
+	encoded[16+7] = 1
+	_, err = gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.NotNil(err)
+	_, err = gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.NotNil(err)
+	should.NotContains(err.Error(), gocodec.ErrFrameBusy.Error())
+	_, err = gocodec.ReadonlyConfig.Unmarshal(encoded, (*TestObject)(nil))
+	should.NotNil(err)
+}
+
+func Test_unmarshal_corrupted_relocation_table(t *testing.T) {
+	should := require.New(t)
+	api := gocodec.Config{RelocationTable: true}.Froze()
+	encoded, err := api.Marshal([]string{"hello"})
+	should.Nil(err)
+	corrupted := append([]byte(nil), encoded...)
+	// the last offset of the table does not point to a pointer word
+	corrupted[len(corrupted)-8] = 3
+	expected := append([]byte(nil), corrupted...)
+	_, err = api.Unmarshal(corrupted, (*[]string)(nil))
+	should.NotNil(err)
+	_, err = api.Relocate(corrupted)
+	should.NotNil(err)
+	should.Equal(expected, corrupted)
+	copy(corrupted, encoded)
+	decoded, err := api.Unmarshal(corrupted, (*[]string)(nil))
+	should.Nil(err)
+	should.Equal([]string{"hello"}, *decoded.(*[]string))
+}
+
+func Test_readonly_unmarshal_frame_decoded_in_place(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 []string
+		Field2 *int
+	}
+	one := 1
+	obj := TestObject{[]string{"hello", "world"}, &one}
+	table := gocodec.Config{RelocationTable: true}.Froze()
+	readonlyTable := gocodec.Config{RelocationTable: true, ReadonlyDecode: true}.Froze()
+	for _, apis := range [][]gocodec.API{{gocodec.DefaultConfig, gocodec.ReadonlyConfig}, {table, readonlyTable}} {
+		encoded, err := apis[0].Marshal(obj)
+		should.Nil(err)
+		_, err = apis[0].Unmarshal(encoded, (*TestObject)(nil))
+		should.Nil(err)
+		decoded, err := apis[1].Unmarshal(encoded, (*TestObject)(nil))
+		should.Nil(err)
+		should.Equal(obj, *decoded.(*TestObject))
+		// the copy does not share any byte with the frame
+		for i := range encoded {
+			encoded[i] = 0
+		}
+		should.Equal(obj, *decoded.(*TestObject))
+	}
+}
+
+func Test_relocate_readonly(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Config{RelocationTable: true}.Froze().Marshal([]string{"hello"})
+	should.Nil(err)
+	original := append([]byte(nil), encoded...)
+	_, err = gocodec.Config{RelocationTable: true, ReadonlyDecode: true}.Froze().Relocate(encoded)
+	should.NotNil(err)
+	should.Equal(original, encoded)
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+	"math"
+)
+
+func Test_diff(t *testing.T) {
+	should := require.New(t)
+	type SubObject struct {
+		Name string
+	}
+	type TestObject struct {
+		Field1 uint8
+		Field2 *SubObject
+		Field3 []uint32
+		Field4 *SubObject
+		Field5 float64
+		Field6 [2]SubObject
+	}
+	a, err := gocodec.Marshal(TestObject{1, &SubObject{"a"}, []uint32{1, 2}, nil, math.NaN(),
+		[2]SubObject{{"x"}, {"y"}}})
+	should.Nil(err)
+	b, err := gocodec.Marshal(TestObject{2, &SubObject{"b"}, []uint32{1, 3, 4}, &SubObject{}, math.NaN(),
+		[2]SubObject{{"x"}, {"z"}}})
+	should.Nil(err)
+	differences, err := gocodec.Diff(a, b, (*TestObject)(nil))
+	should.Nil(err)
+	descriptions := []string{}
+	for _, difference := range differences {
+		descriptions = append(descriptions, difference.String())
+	}
+	should.Equal([]string{
+		`Field1: 1 != 2`,
+		`Field2.Name: "a" != "b"`,
+		`Field3: len 2 != len 3`,
+		`Field3[1]: 2 != 3`,
+		`Field4: nil != non-nil`,
+		`Field6[1].Name: "y" != "z"`,
+	}, descriptions)
+	should.Equal("Field3", differences[2].Path)
+	differences, err = gocodec.Diff(a, a, (*TestObject)(nil))
+	should.Nil(err)
+	should.Len(differences, 0)
+}
+
+func Test_diff_ignores_layout(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 *string
+		Field2 *string
+	}
+	str1 := "hello"
+	str2 := "world"
+	a, err := gocodec.Marshal(TestObject{&str1, &str2})
+	should.Nil(err)
+	b, err := gocodec.Config{RelocationTable: true}.Froze().Marshal(TestObject{&str1, &str2})
+	should.Nil(err)
+	_, err = gocodec.Unmarshal(b, (*TestObject)(nil))
+	should.Nil(err)
+	should.NotEqual(a, b)
+	differences, err := gocodec.Diff(a, b, (*TestObject)(nil))
+	should.Nil(err)
+	should.Len(differences, 0)
+}
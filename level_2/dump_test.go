@@ -0,0 +1,37 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+	"bytes"
+)
+
+func Test_dump(t *testing.T) {
+	should := require.New(t)
+	type SubObject struct {
+		Name string
+	}
+	type TestObject struct {
+		Field1 int
+		Field2 *SubObject
+		Field3 []uint8
+	}
+	encoded, err := gocodec.Marshal(TestObject{1, &SubObject{"hi"}, []uint8{1, 2}})
+	should.Nil(err)
+	output := bytes.NewBuffer(nil)
+	should.Nil(gocodec.Dump(output, encoded, (*TestObject)(nil)))
+	should.Contains(output.String(), "0x0008 Field1 int = 1\n")
+	should.Contains(output.String(), "0x0010 Field2 *test.SubObject = -> 0x0030\n")
+	should.Contains(output.String(), "0x0030 [out of line 16 bytes] Field2\n")
+	should.Contains(output.String(), "0x0030 Field2.Name string = \"hi\" -> 0x0040\n")
+	should.Contains(output.String(), "0x0018 Field3 []uint8 = len 2 -> 0x0042\n")
+	should.Contains(output.String(), "0x0042 Field3 []uint8 = [1 2]\n")
+	// decoded frame can still be dumped
+	_, err = gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	decodedOutput := bytes.NewBuffer(nil)
+	should.Nil(gocodec.Dump(decodedOutput, encoded, (*TestObject)(nil)))
+	should.Equal(output.String(), decodedOutput.String())
+	should.NotNil(gocodec.Dump(output, encoded, (*SubObject)(nil)))
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_encoded_size(t *testing.T) {
+	should := require.New(t)
+	type SubObject struct {
+		Name string
+		Set  []uint64
+	}
+	type TestObject struct {
+		Field1 int
+		Field2 *SubObject
+		Field3 []string
+		Field4 *SubObject
+	}
+	obj := TestObject{1, &SubObject{"hello", []uint64{1, 2}}, []string{"a", "bc"}, nil}
+	for _, api := range []gocodec.API{gocodec.DefaultConfig, gocodec.Config{RelocationTable: true}.Froze()} {
+		encoded, err := api.Marshal(obj)
+		should.Nil(err)
+		size, err := api.EncodedSize(obj)
+		should.Nil(err)
+		should.Equal(len(encoded), size)
+	}
+}
+
+func Test_marshal_to(t *testing.T) {
+	should := require.New(t)
+	obj := [][]byte{[]byte("hello"), []byte("world")}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	dst := make([]byte, 128)
+	n, err := gocodec.MarshalTo(dst, obj)
+	should.Nil(err)
+	should.Equal(encoded, dst[:n])
+	decoded, err := gocodec.Unmarshal(dst, (*[][]byte)(nil))
+	should.Nil(err)
+	should.Equal(obj, *decoded.(*[][]byte))
+	_, err = gocodec.MarshalTo(make([]byte, n-1), obj)
+	should.Equal(gocodec.ErrShortBuffer, err)
+}
@@ -0,0 +1,208 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+type encoderInner struct {
+	Field1 int8
+	Field2 string
+	Field3 []int64
+}
+
+type encoderObject struct {
+	Field1 int8
+	Field2 []encoderInner
+	Field3 *encoderInner
+	Field4 [][]string
+	Field5 []int
+	Field6 []int
+	Field7 string
+	Field8 [2]*string
+	Field9 int `gocodec:"-"`
+}
+
+func newEncoderObject() encoderObject {
+	hello := "hello"
+	return encoderObject{
+		Field1: 1,
+		Field2: []encoderInner{{1, "a", []int64{1, 2}}, {2, "", nil}, {3, "b", []int64{}}},
+		Field3: &encoderInner{4, "c", []int64{3}},
+		Field4: [][]string{nil, {}, {"", "d"}},
+		Field5: []int{},
+		Field7: "",
+		Field8: [2]*string{&hello, nil},
+		Field9: 9,
+	}
+}
+
+func Test_encoder_same_as_marshal(t *testing.T) {
+	should := require.New(t)
+	obj := newEncoderObject()
+	hello := "hello"
+	configs := []gocodec.API{
+		gocodec.DefaultConfig,
+		gocodec.Config{Canonical: true}.Froze(),
+		gocodec.Config{RelocationTable: true}.Froze(),
+	}
+	vals := []interface{}{obj, &obj, &hello, struct{ Field1 *string }{&hello}, []byte("world"), ""}
+	for _, cfg := range configs {
+		expected := []byte{}
+		for _, val := range vals {
+			encoded, err := cfg.Marshal(val)
+			should.Nil(err)
+			expected = append(expected, encoded...)
+		}
+		buf := &bytes.Buffer{}
+		encoder := cfg.NewEncoder(buf)
+		for _, val := range vals {
+			should.Nil(encoder.Encode(val))
+		}
+		should.Equal(expected, buf.Bytes())
+		buf.Reset()
+		should.Nil(encoder.EncodePointer(&obj))
+		encoded, err := cfg.Marshal(obj)
+		should.Nil(err)
+		should.Equal(encoded, buf.Bytes())
+	}
+}
+
+func Test_encoder_time(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 time.Time
+		Field2 time.Time
+	}
+	obj := TestObject{time.Unix(1, 2).UTC(), time.Unix(3, 4).In(time.FixedZone("CST", 8*3600))}
+	expected, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	buf := &bytes.Buffer{}
+	should.Nil(gocodec.NewEncoder(buf).Encode(obj))
+	should.Equal(expected, buf.Bytes())
+	encoder := gocodec.Config{RelocationTable: true}.Froze().NewEncoder(buf)
+	should.NotNil(encoder.Encode(obj))
+}
+
+type recordingWriter struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (writer *recordingWriter) Write(data []byte) (int, error) {
+	writer.writes = append(writer.writes, len(data))
+	return writer.Buffer.Write(data)
+}
+
+func Test_encoder_passes_large_region_through(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 string
+		Field2 []uint64
+	}
+	obj := TestObject{"hello", make([]uint64, 1024*1024)}
+	obj.Field2[1] = 1
+	expected, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	writer := &recordingWriter{}
+	should.Nil(gocodec.NewEncoder(writer).Encode(obj))
+	should.Equal(expected, writer.Bytes())
+	should.Contains(writer.writes, 8*len(obj.Field2))
+}
+
+type failingWriter struct {
+}
+
+func (writer failingWriter) Write(data []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func Test_encoder_error(t *testing.T) {
+	should := require.New(t)
+	err := gocodec.NewEncoder(failingWriter{}).Encode(newEncoderObject())
+	should.NotNil(err)
+	should.Contains(err.Error(), "disk full")
+	should.NotNil(gocodec.NewEncoder(&bytes.Buffer{}).Encode(map[string]int{}))
+	should.NotNil(gocodec.NewEncoder(&bytes.Buffer{}).EncodePointer(newEncoderObject()))
+}
+
+// sneaky is encoded by the codec changing the string it points to,
+// so the value Encoder writes is not the one it has laid out
+type sneaky struct {
+	target *string
+}
+
+type sneakyCodec struct {
+	gocodec.BaseCodec
+}
+
+func (codec *sneakyCodec) Encode(ptr unsafe.Pointer, stream *gocodec.Stream) {
+	*(*sneaky)(ptr).target = "changed"
+	stream.WriteWord(0, 0)
+}
+
+func (codec *sneakyCodec) Decode(iter *gocodec.Iterator) {
+}
+
+func (codec *sneakyCodec) Freeze(iter *gocodec.Iterator) {
+}
+
+func (codec *sneakyCodec) HasPointer() bool {
+	return true
+}
+
+func Test_encoder_drops_failed_frame(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 string
+		Field2 sneaky
+	}
+	cfg := gocodec.Config{}
+	codec := &sneakyCodec{*gocodec.NewBaseCodec(reflect.TypeOf(sneaky{}), 0x736e6b)}
+	cfg.RegisterCodec(reflect.TypeOf(sneaky{}), codec, codec)
+	api := cfg.Froze()
+	obj := TestObject{}
+	obj.Field2.target = &obj.Field1
+	output := &bytes.Buffer{}
+	encoder := api.NewEncoder(output)
+	should.NotNil(encoder.EncodePointer(&obj))
+	should.Equal(0, output.Len())
+	should.Nil(encoder.Encode("hello"))
+	expected, err := api.Marshal("hello")
+	should.Nil(err)
+	should.Equal(expected, output.Bytes())
+}
+
+// callbackWriter calls onWrite after the bytes are written
+type callbackWriter struct {
+	bytes.Buffer
+	onWrite func()
+}
+
+func (writer *callbackWriter) Write(data []byte) (int, error) {
+	n, err := writer.Buffer.Write(data)
+	writer.onWrite()
+	return n, err
+}
+
+func Test_encoder_broken_by_partially_written_frame(t *testing.T) {
+	should := require.New(t)
+	obj := make([]string, 64*1024)
+	writer := &callbackWriter{}
+	writer.onWrite = func() {
+		// the frame is larger than the chunk, the rest is written after the first chunk reached the writer
+		obj[len(obj)-1] = "changed"
+	}
+	encoder := gocodec.NewEncoder(writer)
+	should.NotNil(encoder.EncodePointer(&obj))
+	should.NotEqual(0, writer.Len())
+	written := writer.Len()
+	should.NotNil(encoder.Encode("hello"))
+	should.Equal(written, writer.Len())
+}
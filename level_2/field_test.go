@@ -0,0 +1,65 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_set_field(t *testing.T) {
+	should := require.New(t)
+	type Counter struct {
+		Hits   uint32
+		Misses [2]uint64
+	}
+	type TestObject struct {
+		Name    string
+		Counter Counter
+		Ratio   float64
+	}
+	obj := TestObject{"hello", Counter{1, [2]uint64{2, 3}}, 0.5}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	should.Nil(gocodec.SetField(encoded, (*TestObject)(nil), "Counter.Hits", uint32(100)))
+	handle, err := gocodec.CompileField((*TestObject)(nil), "Counter.Misses[1]")
+	should.Nil(err)
+	should.Nil(handle.Set(encoded, uint64(300)))
+	val, err := handle.Get(encoded)
+	should.Nil(err)
+	should.Equal(uint64(300), val)
+	should.NotNil(handle.Set(encoded, 300))
+	decoded, err := gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(TestObject{"hello", Counter{100, [2]uint64{2, 300}}, 0.5}, *decoded.(*TestObject))
+	// decoded frame still can be patched
+	should.Nil(gocodec.SetField(encoded, (*TestObject)(nil), "Ratio", 1.5))
+	should.Equal(1.5, decoded.(*TestObject).Ratio)
+}
+
+func Test_set_field_rejects_pointer(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Name string
+	}
+	_, err := gocodec.CompileField((*TestObject)(nil), "Name")
+	should.NotNil(err)
+	_, err = gocodec.CompileField((*TestObject)(nil), "Missing")
+	should.NotNil(err)
+	encoded, err := gocodec.Marshal(100)
+	should.Nil(err)
+	should.NotNil(gocodec.SetField(encoded, (*uint)(nil), "", uint(1)))
+}
+
+func Test_set_field_rejects_skipped(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Hits  uint32
+		cache uint32 `gocodec:"-"`
+	}
+	_, err := gocodec.CompileField((*TestObject)(nil), "cache")
+	should.NotNil(err)
+	should.Contains(err.Error(), `gocodec:"-"`)
+	encoded, err := gocodec.Marshal(TestObject{Hits: 1})
+	should.Nil(err)
+	should.NotNil(gocodec.SetField(encoded, (*TestObject)(nil), "cache", uint32(1)))
+}
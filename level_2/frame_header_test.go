@@ -0,0 +1,68 @@
+package test
+
+import (
+	"testing"
+	"unsafe"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_frame_larger_than_512MB(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal(100)
+	should.Nil(err)
+	*(*uint32)(unsafe.Pointer(&encoded[0])) = 3 << 28
+	iter := gocodec.NewIterator(encoded)
+	should.Equal(uint32(3<<28), iter.NextSize())
+	_, err = gocodec.Unmarshal(encoded, (*int)(nil))
+	should.Contains(err.Error(), "truncated")
+}
+
+func Test_plain_frame_header(t *testing.T) {
+	should := require.New(t)
+	// the header is size and signature only, the relocation table comes with the extended header
+	encoded, err := gocodec.Marshal([]string{"hello"})
+	should.Nil(err)
+	should.Equal(uint32(len(encoded)), *(*uint32)(unsafe.Pointer(&encoded[0])))
+	withTable, err := gocodec.Config{RelocationTable: true}.Froze().Marshal([]string{"hello"})
+	should.Nil(err)
+	iter := gocodec.NewIterator(withTable)
+	should.Equal(uint32(len(withTable)), iter.NextSize())
+	should.Equal(gocodec.NewIterator(encoded).NextSignature(), iter.NextSignature())
+}
+
+func Test_frame_of_unknown_format(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal([]string{"hello"})
+	should.Nil(err)
+	// the size less than the header is left to mark other headers
+	*(*uint32)(unsafe.Pointer(&encoded[0])) = 2
+	_, err = gocodec.Unmarshal(encoded, (*[]string)(nil))
+	should.NotNil(err)
+	should.Contains(err.Error(), "format")
+}
+
+func Test_registry_of_frame_decoded_in_place(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal([]string{"hello"})
+	should.Nil(err)
+	_, err = gocodec.Unmarshal(encoded, (*[]string)(nil))
+	should.Nil(err)
+	registry := gocodec.NewRegistry()
+	should.Nil(registry.Register((*[]string)(nil)))
+	decoded, err := registry.Unmarshal(encoded)
+	should.Nil(err)
+	should.Equal([]string{"hello"}, *decoded.(*[]string))
+}
+
+func Test_frame_written_before_padding(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal("hello")
+	should.Nil(err)
+	// the older frame ends right after the last out of line value
+	older := append([]byte(nil), encoded[:8+16+5]...)
+	*(*uint32)(unsafe.Pointer(&older[0])) = uint32(len(older))
+	decoded, err := gocodec.Unmarshal(older, (*string)(nil))
+	should.Nil(err)
+	should.Equal("hello", *decoded.(*string))
+}
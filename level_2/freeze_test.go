@@ -0,0 +1,75 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_freeze(t *testing.T) {
+	should := require.New(t)
+	type SubObject struct {
+		Count int
+		Names []string
+	}
+	type TestObject struct {
+		Field1 int
+		Field2 *SubObject
+		Field3 [][]byte
+		Field4 *SubObject
+	}
+	obj := TestObject{1, &SubObject{2, []string{"a", "bc"}}, [][]byte{[]byte("hello")}, nil}
+	for _, api := range []gocodec.API{gocodec.DefaultConfig, gocodec.Config{RelocationTable: true}.Froze()} {
+		encoded, err := api.Marshal(obj)
+		should.Nil(err)
+		decoded, err := api.Unmarshal(encoded, (*TestObject)(nil))
+		should.Nil(err)
+		decoded.(*TestObject).Field1 = 100
+		decoded.(*TestObject).Field2.Count = 200
+		should.Nil(api.Freeze(encoded, (*TestObject)(nil)))
+		expected, err := api.Marshal(TestObject{100, &SubObject{200, []string{"a", "bc"}}, [][]byte{[]byte("hello")}, nil})
+		should.Nil(err)
+		should.Equal(expected, encoded)
+		decoded, err = api.Unmarshal(encoded, (*TestObject)(nil))
+		should.Nil(err)
+		should.Equal(200, decoded.(*TestObject).Field2.Count)
+	}
+}
+
+func Test_freeze_pointer_out_of_frame(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 *int
+		Field2 *int
+	}
+	one := 1
+	encoded, err := gocodec.Marshal(TestObject{&one, &one})
+	should.Nil(err)
+	expected := append([]byte(nil), encoded...)
+	decoded, err := gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	field2 := decoded.(*TestObject).Field2
+	two := 2
+	decoded.(*TestObject).Field2 = &two
+	relocated := append([]byte(nil), encoded...)
+	should.NotNil(gocodec.Freeze(encoded, (*TestObject)(nil)))
+	// nothing written, the frame is still decoded in place
+	should.Equal(relocated, encoded)
+	decoded, err = gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(1, *decoded.(*TestObject).Field1)
+	decoded.(*TestObject).Field2 = field2
+	should.Nil(gocodec.Freeze(encoded, (*TestObject)(nil)))
+	should.Equal(expected, encoded)
+}
+
+func Test_freeze_readonly(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal([]string{"hello"})
+	should.Nil(err)
+	_, err = gocodec.ReadonlyConfig.Unmarshal(encoded, (*[]string)(nil))
+	should.Nil(err)
+	err = gocodec.ReadonlyConfig.Freeze(encoded, (*[]string)(nil))
+	should.NotNil(err)
+	should.Contains(err.Error(), "readonly")
+}
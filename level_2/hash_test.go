@@ -0,0 +1,110 @@
+package test
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"runtime"
+	"testing"
+	"unsafe"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+type hashSubObject struct {
+	Field1 uint8
+	Field2 string
+	Field3 []int16
+}
+
+type hashObject struct {
+	Field1 uint8
+	Field2 *hashSubObject
+	Field3 []hashSubObject
+	Field4 [2]string
+	Field5 []string
+	Field6 *int64
+}
+
+func newHashObject() hashObject {
+	return hashObject{
+		Field1: 1,
+		Field2: &hashSubObject{2, "hello", []int16{3, 4}},
+		Field3: []hashSubObject{{5, "", nil}, {6, "world", []int16{7}}},
+		Field4: [2]string{"a", ""},
+		Field5: make([]string, 0, 4),
+	}
+}
+
+func Test_hash_is_digest_of_canonical_frame(t *testing.T) {
+	should := require.New(t)
+	obj := newHashObject()
+	encoded, err := gocodec.Config{Canonical: true}.Froze().Marshal(obj)
+	should.Nil(err)
+	expected := sha256.Sum256(encoded)
+	digest, err := gocodec.Hash(sha256.New(), obj)
+	should.Nil(err)
+	should.Equal(expected[:], digest)
+	digest, err = gocodec.Hash(sha256.New(), &obj)
+	should.Nil(err)
+	encoded, err = gocodec.Config{Canonical: true}.Froze().Marshal(&obj)
+	should.Nil(err)
+	expected = sha256.Sum256(encoded)
+	should.Equal(expected[:], digest)
+	hash := sha512.New()
+	digest, err = gocodec.Hash(hash, obj)
+	should.Nil(err)
+	encoded, err = gocodec.Config{Canonical: true}.Froze().Marshal(obj)
+	should.Nil(err)
+	expected512 := sha512.Sum512(encoded)
+	should.Equal(expected512[:], digest)
+	hash.Reset()
+	encoded, err = gocodec.Marshal(obj)
+	should.Nil(err)
+	digest, err = gocodec.HashFrame(hash, encoded, (*hashObject)(nil))
+	should.Nil(err)
+	should.Equal(expected512[:], digest)
+}
+
+func Test_hash_frame(t *testing.T) {
+	should := require.New(t)
+	obj := newHashObject()
+	expected, err := gocodec.Hash(sha256.New(), obj)
+	should.Nil(err)
+	// padding is dirty, and the frame has relocation table
+	dirty := newHashObject()
+	(*[8]byte)(unsafe.Pointer(&dirty))[1] = 0xff
+	encoded, err := gocodec.Config{RelocationTable: true}.Froze().Marshal(dirty)
+	should.Nil(err)
+	digest, err := gocodec.HashFrame(sha256.New(), encoded, (*hashObject)(nil))
+	should.Nil(err)
+	should.Equal(expected, digest)
+	decoded, err := gocodec.Unmarshal(encoded, (*hashObject)(nil))
+	should.Nil(err)
+	digest, err = gocodec.HashFrame(sha256.New(), encoded, (*hashObject)(nil))
+	should.Nil(err)
+	should.Equal(expected, digest)
+	digest, err = gocodec.Hash(sha256.New(), *decoded.(*hashObject))
+	should.Nil(err)
+	should.Equal(expected, digest)
+	obj.Field3[1].Field3[0] = 8
+	digest, err = gocodec.Hash(sha256.New(), obj)
+	should.Nil(err)
+	should.NotEqual(expected, digest)
+}
+
+func Test_hash_reuses_encoder(t *testing.T) {
+	should := require.New(t)
+	obj := newHashObject()
+	hash := sha256.New()
+	gocodec.Hash(hash, &obj)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 100; i++ {
+		hash.Reset()
+		gocodec.Hash(hash, &obj)
+	}
+	runtime.ReadMemStats(&after)
+	// the chunk of Encoder alone is 64KB, the pool drops some of them on purpose under the race detector
+	perRun := (after.TotalAlloc - before.TotalAlloc) / 100
+	should.True(perRun < 32*1024, "%v bytes allocated", perRun)
+}
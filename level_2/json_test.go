@@ -0,0 +1,131 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+	"math"
+	"time"
+	_ "time/tzdata"
+	"unsafe"
+)
+
+func Test_json_round_trip(t *testing.T) {
+	should := require.New(t)
+	type SubObject struct {
+		name  string
+		Ratio float32
+	}
+	type TestObject struct {
+		Field1 int8
+		Field2 *SubObject
+		Field3 []string
+		Field4 [2]uint64
+		Field5 []byte
+		Field6 *SubObject
+		Field7 float64
+	}
+	obj := TestObject{-1, &SubObject{"hello", 0.5}, []string{"a", "b"}, [2]uint64{math.MaxUint64, 2}, []byte("abc"), nil, math.Inf(1)}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	output, err := gocodec.ToJSON(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(`{"Field1":-1,"Field2":{"name":"hello","Ratio":0.5},"Field3":["a","b"],`+
+		`"Field4":[18446744073709551615,2],"Field5":"YWJj","Field6":null,"Field7":"+Inf"}`, string(output))
+	frame, err := gocodec.FromJSON(output, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(encoded, frame)
+}
+
+func Test_json_round_trip_padding_and_zone(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 int8
+		Field2 time.Time
+		Field3 time.Time
+		Field4 time.Time
+		Field5 []time.Time
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	should.Nil(err)
+	obj := TestObject{
+		Field1: 1,
+		Field2: time.Unix(1500000000, 5).In(newYork),
+		// local mean time, the utc offset has seconds
+		Field3: time.Date(1880, 1, 1, 0, 0, 0, 0, newYork),
+		Field4: time.Unix(1500000000, 0).Local(),
+		Field5: []time.Time{time.Unix(1500000000, 0).UTC()},
+	}
+	// the padding after Field1 is copied as it is, unless canonical
+	*(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(&obj)) + 1)) = 0xab
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	output, err := gocodec.ToJSON(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Contains(string(output), `"Field2":"2017-07-13T22:40:00.000000005-04:00[America/New_York]"`)
+	should.Contains(string(output), `"Field3":"1880-01-01T00:00:00-04:56:02[America/New_York]"`)
+	should.Contains(string(output), `[Local]"`)
+	should.Contains(string(output), `"Field5":["2017-07-14T02:40:00Z"]`)
+	should.Contains(string(output), `"[padding]":"ab000000000000"`)
+	frame, err := gocodec.FromJSON(output, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(encoded, frame)
+	decoded, err := gocodec.Unmarshal(frame, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(newYork, decoded.(*TestObject).Field2.Location())
+}
+
+func Test_json_round_trip_relocation_table(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 *string
+		Field2 []string
+		Field3 []int
+	}
+	str := "hello"
+	cfg := gocodec.Config{RelocationTable: true}.Froze()
+	encoded, err := cfg.Marshal(TestObject{&str, []string{"a", ""}, []int{}})
+	should.Nil(err)
+	output, err := cfg.ToJSON(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(`{"Field1":"hello","Field2":["a",""],"Field3":[]}`, string(output))
+	frame, err := cfg.FromJSON(output, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(encoded, frame)
+}
+
+func Test_json_errors(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 int8
+	}
+	_, err := gocodec.FromJSON([]byte(`{"Field1":1000}`), (*TestObject)(nil))
+	should.NotNil(err)
+	_, err = gocodec.FromJSON([]byte(`{"Field2":1}`), (*TestObject)(nil))
+	should.NotNil(err)
+	encoded, err := gocodec.Marshal("hello")
+	should.Nil(err)
+	_, err = gocodec.ToJSON(encoded, (*TestObject)(nil))
+	should.NotNil(err)
+}
+
+type testEmbedded struct {
+	X int
+}
+
+func Test_json_promoted_field(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		testEmbedded
+		Y int
+	}
+	// X is within the embedded struct, it is not a field of its own
+	_, err := gocodec.FromJSON([]byte(`{"X":1,"Y":2}`), (*TestObject)(nil))
+	should.NotNil(err)
+	should.Contains(err.Error(), "has no field X")
+	encoded, err := gocodec.FromJSON([]byte(`{"testEmbedded":{"X":1},"Y":2}`), (*TestObject)(nil))
+	should.Nil(err)
+	decoded, err := gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(TestObject{testEmbedded{1}, 2}, *decoded.(*TestObject))
+}
@@ -0,0 +1,84 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_marshal_pointer(t *testing.T) {
+	should := require.New(t)
+	type SinglePointer struct {
+		Field1 *string
+	}
+	type TestObject struct {
+		Field1 [1024]int
+		Field2 []string
+		Field3 *SinglePointer
+	}
+	hello := "hello"
+	single := SinglePointer{&hello}
+	obj := TestObject{Field2: []string{"a", "b"}, Field3: &single}
+	obj.Field1[1] = 1
+	pHello := &hello
+	array := [1]*string{&hello}
+	vals := []interface{}{obj, single, pHello, array, hello}
+	ptrs := []interface{}{&obj, &single, &pHello, &array, &hello}
+	for i, val := range vals {
+		expected, err := gocodec.Marshal(val)
+		should.Nil(err)
+		encoded, err := gocodec.MarshalPointer(ptrs[i])
+		should.Nil(err)
+		should.Equal(expected, encoded)
+	}
+	_, err := gocodec.MarshalPointer(obj)
+	should.NotNil(err)
+	_, err = gocodec.MarshalPointer((*TestObject)(nil))
+	should.NotNil(err)
+}
+
+func Test_marshal_nested_single_pointer(t *testing.T) {
+	should := require.New(t)
+	type Inner struct {
+		P *string
+	}
+	type TestObject struct {
+		Inner Inner
+	}
+	hello := "hello"
+	for _, obj := range []interface{}{TestObject{Inner{&hello}}, [1]TestObject{{Inner{&hello}}}} {
+		encoded, err := gocodec.Marshal(obj)
+		should.Nil(err)
+		buf := &bytes.Buffer{}
+		should.Nil(gocodec.NewEncoder(buf).Encode(obj))
+		should.Equal(encoded, buf.Bytes())
+		size, err := gocodec.EncodedSize(obj)
+		should.Nil(err)
+		should.Equal(len(encoded), size)
+	}
+	obj := TestObject{Inner{&hello}}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	byPointer, err := gocodec.MarshalPointer(&obj)
+	should.Nil(err)
+	should.Equal(encoded, byPointer)
+	decoded, err := gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal("hello", *decoded.(*TestObject).Inner.P)
+}
+
+func Test_marshal_pointer_without_copy(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 [1024]int
+	}
+	obj := TestObject{}
+	stream := gocodec.NewStream(make([]byte, 0, 16*1024))
+	allocs := testing.AllocsPerRun(100, func() {
+		stream.Reset(stream.Buffer()[:0])
+		stream.MarshalPointer(&obj)
+	})
+	should.Nil(stream.Error)
+	should.Equal(float64(0), allocs)
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mux(t *testing.T) {
+	should := require.New(t)
+	type Created struct {
+		Id   uint64
+		Name string
+	}
+	type Deleted struct {
+		Id     uint64
+		Reason []string
+	}
+	stream := gocodec.NewStream(nil)
+	stream.Marshal(Created{1, "a"})
+	stream.Marshal("unknown")
+	stream.Marshal(Deleted{1, []string{"expired"}})
+	should.Nil(stream.Error)
+	events := []interface{}{}
+	mux := gocodec.NewMux()
+	should.Nil(mux.Handle(func(event *Created) error {
+		events = append(events, *event)
+		return nil
+	}))
+	should.Nil(mux.Handle(func(event *Deleted) error {
+		events = append(events, *event)
+		return nil
+	}))
+	should.NotNil(mux.Handle(func(event *Deleted) error {
+		return nil
+	}))
+	should.NotNil(mux.Handle(func(event Deleted) {}))
+	should.NotNil(mux.DispatchAll(stream.Buffer()))
+	should.Equal([]interface{}{Created{1, "a"}}, events)
+	events = nil
+	mux.HandleUnknown(gocodec.SkipUnknown)
+	should.Nil(mux.DispatchAll(stream.Buffer()))
+	should.Equal([]interface{}{Created{1, "a"}, Deleted{1, []string{"expired"}}}, events)
+	unknown := 0
+	mux.HandleUnknown(func(signature uint32, frame []byte) error {
+		unknown++
+		decoded, err := gocodec.Unmarshal(frame, (*string)(nil))
+		should.Nil(err)
+		should.Equal("unknown", *decoded.(*string))
+		return nil
+	})
+	should.Nil(mux.DispatchAll(stream.Buffer()))
+	should.Equal(1, unknown)
+}
+
+func Test_mux_handler_error(t *testing.T) {
+	should := require.New(t)
+	type Event struct {
+		Id uint64
+	}
+	stream := gocodec.NewStream(nil)
+	stream.Marshal(Event{1})
+	stream.Marshal(Event{2})
+	mux := gocodec.NewMux()
+	stopped := errors.New("stopped")
+	handled := 0
+	should.Nil(mux.Handle(func(event *Event) error {
+		handled++
+		return stopped
+	}))
+	should.Equal(stopped, mux.DispatchAll(stream.Buffer()))
+	should.Equal(1, handled)
+}
+
+func Test_mux_corrupted_tail(t *testing.T) {
+	should := require.New(t)
+	type Event struct {
+		Id uint64
+	}
+	encoded, err := gocodec.Marshal(Event{1})
+	should.Nil(err)
+	mux := gocodec.NewMux()
+	mux.HandleUnknown(gocodec.SkipUnknown)
+	handled := 0
+	should.Nil(mux.Handle(func(event *Event) error {
+		handled++
+		return nil
+	}))
+	should.Nil(mux.DispatchAll(encoded))
+	for _, tail := range [][]byte{
+		{1, 2, 3, 4},
+		{0, 0, 0, 0, 0, 0, 0, 0},
+		encoded[:len(encoded)-1],
+	} {
+		handled = 0
+		err := mux.DispatchAll(append(append([]byte(nil), encoded...), tail...))
+		should.NotNil(err)
+		should.Equal(1, handled)
+	}
+}
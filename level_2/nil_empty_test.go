@@ -0,0 +1,81 @@
+package test
+
+import (
+	"crypto/sha256"
+	"reflect"
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+type nilEmptyObject struct {
+	Field1 []int
+	Field2 []int
+	Field3 [][]string
+	Field4 []string
+	Field5 string
+}
+
+func Test_nil_and_empty_round_trip(t *testing.T) {
+	should := require.New(t)
+	obj := nilEmptyObject{nil, []int{}, [][]string{nil, {}, {""}}, make([]string, 0, 10), ""}
+	configs := []gocodec.API{
+		gocodec.DefaultConfig,
+		gocodec.ReadonlyConfig,
+		gocodec.Config{RelocationTable: true}.Froze(),
+	}
+	for _, cfg := range configs {
+		encoded, err := cfg.Marshal(obj)
+		should.Nil(err)
+		size, err := cfg.EncodedSize(obj)
+		should.Nil(err)
+		should.Equal(len(encoded), size)
+		decoded, err := cfg.Unmarshal(encoded, (*nilEmptyObject)(nil))
+		should.Nil(err)
+		should.True(reflect.DeepEqual(obj, *decoded.(*nilEmptyObject)))
+		if cfg == gocodec.ReadonlyConfig {
+			// not decoded in place, nothing to freeze
+			continue
+		}
+		should.Nil(cfg.Freeze(encoded, (*nilEmptyObject)(nil)))
+		decoded, err = cfg.Unmarshal(encoded, (*nilEmptyObject)(nil))
+		should.Nil(err)
+		should.True(reflect.DeepEqual(obj, *decoded.(*nilEmptyObject)))
+	}
+}
+
+func Test_empty_string_at_frame_end(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 string
+		Field2 string
+	}
+	encoded, err := gocodec.Marshal(TestObject{"hello", ""})
+	should.Nil(err)
+	decoded, err := gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(TestObject{"hello", ""}, *decoded.(*TestObject))
+}
+
+func Test_nil_and_empty_tools(t *testing.T) {
+	should := require.New(t)
+	encodedNil, err := gocodec.Marshal(nilEmptyObject{})
+	should.Nil(err)
+	encodedEmpty, err := gocodec.Marshal(nilEmptyObject{Field1: []int{}})
+	should.Nil(err)
+	json, err := gocodec.ToJSON(encodedEmpty, (*nilEmptyObject)(nil))
+	should.Nil(err)
+	should.Equal(`{"Field1":[],"Field2":null,"Field3":null,"Field4":null,"Field5":""}`, string(json))
+	differences, err := gocodec.Diff(encodedNil, encodedEmpty, (*nilEmptyObject)(nil))
+	should.Nil(err)
+	should.Len(differences, 1)
+	should.Equal("Field1: nil != empty", differences[0].String())
+	hashNil, err := gocodec.Hash(sha256.New(), nilEmptyObject{})
+	should.Nil(err)
+	hashEmpty, err := gocodec.Hash(sha256.New(), nilEmptyObject{Field1: []int{}})
+	should.Nil(err)
+	should.NotEqual(hashNil, hashEmpty)
+	hashFrame, err := gocodec.HashFrame(sha256.New(), encodedEmpty, (*nilEmptyObject)(nil))
+	should.Nil(err)
+	should.Equal(hashEmpty, hashFrame)
+}
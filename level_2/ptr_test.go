@@ -15,7 +15,7 @@ func Test_ptr_of_string(t *testing.T) {
 		0x8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
 		0x10, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
 		0x5, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
-		0x68, 0x65, 0x6c, 0x6c, 0x6f,
+		0x68, 0x65, 0x6c, 0x6c, 0x6f, 0, 0, 0,
 	}, encoded[8:])
 	decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (**string)(nil))
 	should.Nil(err)
@@ -35,7 +35,7 @@ func Test_ptr_of_slice(t *testing.T) {
 		0x18, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
 		0x5, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
 		0x5, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
-		0x68, 0x65, 0x6c, 0x6c, 0x6f,
+		0x68, 0x65, 0x6c, 0x6c, 0x6f, 0, 0, 0,
 	}, encoded[8:])
 	decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (**[]byte)(nil))
 	should.Nil(err)
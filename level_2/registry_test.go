@@ -0,0 +1,49 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_registry(t *testing.T) {
+	should := require.New(t)
+	type Order struct {
+		Id    uint64
+		Items []string
+	}
+	type Refund struct {
+		OrderId uint64
+		Reason  string
+	}
+	registry := gocodec.NewRegistry()
+	should.Nil(registry.Register((*Order)(nil), (*Refund)(nil)))
+	should.Nil(registry.Register((*Order)(nil)))
+	stream := gocodec.NewStream(nil)
+	stream.Marshal(Order{1, []string{"apple"}})
+	stream.Marshal(Refund{1, "broken"})
+	should.Nil(stream.Error)
+	iter := gocodec.NewIterator(stream.Buffer())
+	should.Equal(Order{1, []string{"apple"}}, *iter.UnmarshalRegistered(registry).(*Order))
+	should.Equal(Refund{1, "broken"}, *iter.UnmarshalRegistered(registry).(*Refund))
+	should.Nil(iter.Error)
+	encoded, err := gocodec.Marshal("hello")
+	should.Nil(err)
+	_, err = registry.Unmarshal(encoded)
+	should.NotNil(err)
+}
+
+func Test_registry_collision(t *testing.T) {
+	should := require.New(t)
+	type Celsius struct {
+		Value float64
+	}
+	type Fahrenheit struct {
+		Value float64
+	}
+	registry := gocodec.NewRegistry()
+	should.Nil(registry.Register((*Celsius)(nil)))
+	err := registry.Register((*Fahrenheit)(nil))
+	should.NotNil(err)
+	should.Contains(err.Error(), "collides")
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+	"unsafe"
+)
+
+func Test_relocation_table(t *testing.T) {
+	should := require.New(t)
+	type SubObject struct {
+		Name string
+		Set  []uint64
+	}
+	type TestObject struct {
+		Field1 int
+		Field2 *SubObject
+		Field3 []string
+	}
+	obj := TestObject{1, &SubObject{"hello", []uint64{1, 2}}, []string{"a", "b"}}
+	api := gocodec.Config{RelocationTable: true}.Froze()
+	encoded, err := api.Marshal(obj)
+	should.Nil(err)
+	plain, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	// same as the plain frame after the extended header, but the padding at the end,
+	// which makes room for the table to end at 8 bytes
+	should.Equal(plain[8:len(plain)-8], encoded[16:len(plain)])
+	should.Equal(0, len(encoded)%8)
+	decoded, err := gocodec.ReadonlyConfig.Unmarshal(append([]byte(nil), encoded...), (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(obj, *decoded.(*TestObject))
+	decoded, err = gocodec.Unmarshal(append([]byte(nil), encoded...), (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(obj, *decoded.(*TestObject))
+	root, err := gocodec.Relocate(encoded)
+	should.Nil(err)
+	should.Equal(obj, *(*TestObject)(unsafe.Pointer(&root[0])))
+}
+
+func Test_relocate_without_table(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal([]string{"hello"})
+	should.Nil(err)
+	_, err = gocodec.Relocate(encoded)
+	should.NotNil(err)
+}
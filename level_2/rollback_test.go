@@ -0,0 +1,126 @@
+package test
+
+import (
+	"testing"
+	"runtime/debug"
+	"unsafe"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+// pointer to nowhere, reading it panics with SetPanicOnFault
+const unmappedAddress = 4096
+
+func Test_marshal_truncates_on_error(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 int
+		Field2 *int
+	}
+	stream := gocodec.NewStream(nil)
+	one := 1
+	stream.Marshal(TestObject{1, &one})
+	should.Nil(stream.Error)
+	good := append([]byte(nil), stream.Buffer()...)
+	stream.Marshal(map[string]int{})
+	should.NotNil(stream.Error)
+	should.Equal(good, stream.Buffer())
+	stream.Rollback(stream.Checkpoint())
+	bad := &TestObject{}
+	*(*uintptr)(unsafe.Pointer(&bad.Field2)) = unmappedAddress
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	should.Equal(uint32(0), stream.Marshal(*bad))
+	should.NotNil(stream.Error)
+	should.Equal(good, stream.Buffer())
+	should.Equal(uint32(0), stream.Marshal(TestObject{}))
+	should.Equal(good, stream.Buffer())
+}
+
+func Test_rollback(t *testing.T) {
+	should := require.New(t)
+	stream := gocodec.NewStream(nil)
+	stream.Marshal("a")
+	checkpoint := stream.Checkpoint()
+	stream.Marshal("b")
+	stream.Marshal(map[string]int{})
+	should.NotNil(stream.Error)
+	stream.Rollback(checkpoint)
+	should.Nil(stream.Error)
+	stream.Marshal("c")
+	should.Nil(stream.Error)
+	iter := gocodec.NewIterator(stream.Buffer())
+	should.Equal("a", *iter.Unmarshal((*string)(nil)).(*string))
+	should.Equal("c", *iter.Unmarshal((*string)(nil)).(*string))
+	should.Equal(0, len(iter.Buffer()))
+}
+
+func Test_marshal_error_has_field_path(t *testing.T) {
+	should := require.New(t)
+	type Item struct {
+		Id   int
+		Name *string
+	}
+	type TestObject struct {
+		Id    int
+		Items []Item
+	}
+	items := make([]Item, 4)
+	*(*uintptr)(unsafe.Pointer(&items[3].Name)) = unmappedAddress
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	_, err := gocodec.Marshal(TestObject{1, items})
+	should.NotNil(err)
+	should.Contains(err.Error(), "Root.Items[3].Name: ")
+	// the path goes through pointer and array, also when the value is given by pointer
+	type Outer struct {
+		Inner *[2]Item
+	}
+	inner := [2]Item{items[0], items[3]}
+	_, err = gocodec.MarshalPointer(&Outer{&inner})
+	should.NotNil(err)
+	should.Contains(err.Error(), "Root.Inner[1].Name: ")
+}
+
+func Test_unsupported_type_has_field_path(t *testing.T) {
+	should := require.New(t)
+	type Item struct {
+		Tags map[string]int
+	}
+	type TestObject struct {
+		Items []Item
+	}
+	_, err := gocodec.Marshal(TestObject{})
+	should.NotNil(err)
+	should.Contains(err.Error(), "Root.Items[].Tags: unsupported type map[string]int")
+	_, err = gocodec.Unmarshal([]byte{}, (*TestObject)(nil))
+	should.NotNil(err)
+	encoded, err := gocodec.Marshal(1)
+	should.Nil(err)
+	_, err = gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Contains(err.Error(), "Root.Items[].Tags: unsupported type map[string]int")
+}
+
+func Test_rollback_checkpoint_out_of_buffer(t *testing.T) {
+	should := require.New(t)
+	stream := gocodec.NewStream(nil)
+	stream.Marshal("a")
+	encoded := append([]byte(nil), stream.Buffer()...)
+	stream.Rollback(len(encoded) + 1)
+	should.NotNil(stream.Error)
+	should.Equal(encoded, stream.Buffer())
+	should.Equal(uint32(0), stream.Marshal("b"))
+	stream.Rollback(-1)
+	should.NotNil(stream.Error)
+	stream.Rollback(len(encoded))
+	should.Nil(stream.Error)
+	should.Equal(encoded, stream.Buffer())
+}
+
+func Test_reset_clears_error(t *testing.T) {
+	should := require.New(t)
+	stream := gocodec.NewStream(nil)
+	stream.Marshal(map[string]int{})
+	should.NotNil(stream.Error)
+	stream.Reset(nil)
+	should.Nil(stream.Error)
+	should.NotEqual(uint32(0), stream.Marshal("a"))
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+	"sync"
+)
+
+func Test_shared_view(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Id    int
+		Names []string
+	}
+	stream := gocodec.NewStream(nil)
+	for i := 0; i < 10; i++ {
+		stream.Marshal(TestObject{i, []string{"hello", "world"}})
+	}
+	should.Nil(stream.Error)
+	view, err := gocodec.NewSharedView(stream.Buffer(), (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(10, view.Len())
+	decoded := make([][]*TestObject, 8)
+	wg := sync.WaitGroup{}
+	for g := range decoded {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < view.Len(); i++ {
+				val, err := view.Get(i)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				decoded[g] = append(decoded[g], val.(*TestObject))
+			}
+		}(g)
+	}
+	wg.Wait()
+	for g := range decoded {
+		should.Len(decoded[g], 10)
+		for i, obj := range decoded[g] {
+			should.True(decoded[0][i] == obj)
+			should.Equal(TestObject{i, []string{"hello", "world"}}, *obj)
+		}
+	}
+	_, err = view.Get(10)
+	should.NotNil(err)
+}
@@ -16,7 +16,7 @@ func Test_string_slice(t *testing.T) {
 		0x18, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, // sliceHeader
 		0x20, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0,                         // string header
 		0x11, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0,                         // string header
-		'h', 'i'}, encoded[8:])
+		'h', 'i', 0, 0, 0, 0, 0, 0}, encoded[8:])
 	decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*[]string)(nil))
 	should.Nil(err)
 	should.Equal([]string{"h", "i"}, *decoded.(*[]string))
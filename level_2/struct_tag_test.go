@@ -0,0 +1,154 @@
+package test
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_skipped_field(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 int
+		lock   sync.Mutex `gocodec:"-"`
+		cache  *int       `gocodec:"-"`
+		notify chan int   `gocodec:"-"`
+		Field2 string
+	}
+	cached := 100
+	obj := TestObject{Field1: 1, cache: &cached, notify: make(chan int), Field2: "hello"}
+	obj.lock.Lock()
+	encoded, err := gocodec.Marshal(&obj)
+	should.Nil(err)
+	decoded, err := gocodec.Unmarshal(encoded, (**TestObject)(nil))
+	should.Nil(err)
+	decodedObj := *decoded.(**TestObject)
+	should.Equal(1, decodedObj.Field1)
+	should.Equal("hello", decodedObj.Field2)
+	should.Nil(decodedObj.cache)
+	should.Nil(decodedObj.notify)
+	should.True(decodedObj.lock.TryLock())
+	json, err := gocodec.ToJSON(encoded, (**TestObject)(nil))
+	should.Nil(err)
+	should.Equal(`{"Field1":1,"Field2":"hello"}`, string(json))
+	type Untagged struct {
+		Field1 int
+		lock   sync.Mutex
+		cache  *int
+		notify *int
+		Field2 string
+	}
+	_, err = gocodec.Unmarshal(encoded, (**Untagged)(nil))
+	should.NotNil(err)
+}
+
+func Test_copied_field(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 []string `gocodec:"copy"`
+		Field2 *string  `gocodec:"copy"`
+		Field3 string
+	}
+	hello := "hello"
+	encoded, err := gocodec.Marshal(TestObject{[]string{"a", "b"}, &hello, "world"})
+	should.Nil(err)
+	for _, cfg := range []gocodec.API{gocodec.DefaultConfig, gocodec.ReadonlyConfig} {
+		buf := append([]byte(nil), encoded...)
+		decoded, err := cfg.Unmarshal(buf, (*TestObject)(nil))
+		should.Nil(err)
+		field1 := decoded.(*TestObject).Field1
+		field2 := decoded.(*TestObject).Field2
+		field3 := decoded.(*TestObject).Field3
+		for i := range buf {
+			buf[i] = 0
+		}
+		runtime.GC()
+		should.Equal([]string{"a", "b"}, field1)
+		should.Equal("hello", *field2)
+		should.NotEqual("world", field3)
+	}
+	_, err = gocodec.Config{RelocationTable: true}.Froze().Marshal(TestObject{})
+	should.NotNil(err)
+	type Untagged struct {
+		Field1 []string
+		Field2 *string
+		Field3 string
+	}
+	_, err = gocodec.Unmarshal(encoded, (*Untagged)(nil))
+	should.NotNil(err)
+}
+
+func Test_copied_field_in_slice(t *testing.T) {
+	should := require.New(t)
+	type Item struct {
+		Id   int
+		Tags []string `gocodec:"copy"`
+	}
+	type TestObject struct {
+		Items *[]Item
+	}
+	items := []Item{{1, []string{"a"}}, {2, []string{"b", "c"}}}
+	encoded, err := gocodec.Marshal(TestObject{&items})
+	should.Nil(err)
+	decoded, err := gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	val := decoded.(*TestObject)
+	for i := range encoded {
+		encoded[i] = 0
+	}
+	// the copies are only referenced from the decoded value, they must survive the collection
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		_ = make([]string, 1024)
+	}
+	should.Equal(items, *val.Items)
+}
+
+func Test_unknown_field_tag(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 int `gocodec:"omitempty"`
+	}
+	_, err := gocodec.Marshal(TestObject{})
+	should.Contains(err.Error(), "Root.Field1")
+}
+
+func Test_copied_field_decoded_while_collecting(t *testing.T) {
+	should := require.New(t)
+	type Item struct {
+		Name *string `gocodec:"copy"`
+	}
+	type TestObject struct {
+		Items []Item
+	}
+	names := []string{"a", "b", "c"}
+	obj := TestObject{[]Item{{&names[0]}, {&names[1]}, {&names[2]}}}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+	// the pointers into the copies on heap are only kept alive if they are stored with write barrier
+	decoded := make([]*TestObject, 1000)
+	for i := range decoded {
+		buf := append([]byte(nil), encoded...)
+		val, err := gocodec.Unmarshal(buf, (*TestObject)(nil))
+		should.Nil(err)
+		decoded[i] = val.(*TestObject)
+	}
+	runtime.GC()
+	for _, val := range decoded {
+		should.Equal(obj, *val)
+	}
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+	"time"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_time_utc(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 time.Time
+		Field2 *time.Time
+	}
+	now := time.Unix(1500000000, 123).UTC()
+	obj := TestObject{now, &now}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(obj, *decoded.(*TestObject))
+	decoded, err = gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(obj, *decoded.(*TestObject))
+	should.Equal(time.UTC, decoded.(*TestObject).Field1.Location())
+	should.Nil(gocodec.Freeze(encoded, (*TestObject)(nil)))
+	decoded, err = gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(obj, *decoded.(*TestObject))
+}
+
+func Test_time_utc_decode_without_allocation(t *testing.T) {
+	should := require.New(t)
+	type Baseline struct {
+		Field1 int64
+		Field2 int64
+		Field3 *int64
+	}
+	decodeAllocs := func(val interface{}, candidatePointer interface{}) float64 {
+		encoded, err := gocodec.Marshal(val)
+		should.Nil(err)
+		buf := make([]byte, len(encoded))
+		iter := gocodec.NewIterator(nil)
+		allocs := testing.AllocsPerRun(100, func() {
+			copy(buf, encoded)
+			iter.Reset(buf)
+			iter.Unmarshal(candidatePointer)
+		})
+		should.Nil(iter.Error)
+		return allocs
+	}
+	// the value of same size and shape tells the allocation of Unmarshal itself
+	should.Equal(decodeAllocs(Baseline{}, (*Baseline)(nil)),
+		decodeAllocs(time.Unix(1500000000, 123).UTC(), (*time.Time)(nil)))
+}
+
+func Test_time_zones(t *testing.T) {
+	should := require.New(t)
+	zones := []*time.Location{time.Local, time.FixedZone("XYZ", 3600)}
+	if newYork, err := time.LoadLocation("America/New_York"); err == nil {
+		zones = append(zones, newYork)
+	}
+	for _, zone := range zones {
+		val := time.Unix(1500000000, 0).In(zone)
+		encoded, err := gocodec.Marshal([]time.Time{val})
+		should.Nil(err)
+		decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*[]time.Time)(nil))
+		should.Nil(err)
+		should.True(val.Equal((*decoded.(*[]time.Time))[0]))
+		should.Equal(val.String(), (*decoded.(*[]time.Time))[0].String())
+		decoded, err = gocodec.Unmarshal(encoded, (*[]time.Time)(nil))
+		should.Nil(err)
+		should.Equal(val.String(), (*decoded.(*[]time.Time))[0].String())
+	}
+}
+
+func Test_time_tools(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 time.Time
+	}
+	obj := TestObject{time.Unix(1500000000, 5).In(time.FixedZone("XYZ", 3600))}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	output, err := gocodec.ToJSON(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(`{"Field1":"2017-07-14T03:40:00.000000005+01:00[XYZ]"}`, string(output))
+	canonical, err := gocodec.Config{Canonical: true}.Froze().Marshal(obj)
+	should.Nil(err)
+	expected := sha256.Sum256(canonical)
+	digest, err := gocodec.Hash(sha256.New(), obj)
+	should.Nil(err)
+	should.Equal(expected[:], digest)
+	digest, err = gocodec.HashFrame(sha256.New(), encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(expected[:], digest)
+	_, err = gocodec.Config{RelocationTable: true}.Froze().Marshal(obj)
+	should.NotNil(err)
+	should.NotNil(gocodec.Config{RelocationTable: true}.Froze().NewEncoder(&bytes.Buffer{}).Encode(obj))
+}
+
+func Test_time_freeze_named_zone(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 *int
+		Field2 time.Time
+	}
+	one := 1
+	obj := TestObject{&one, time.Unix(1500000000, 5).In(time.FixedZone("XYZ", 3600))}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	_, err = gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	relocated := append([]byte(nil), encoded...)
+	should.NotNil(gocodec.Freeze(encoded, (*TestObject)(nil)))
+	should.Equal(relocated, encoded)
+	decoded, err := gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	should.Equal(1, *decoded.(*TestObject).Field1)
+	should.Equal(obj.Field2.String(), decoded.(*TestObject).Field2.String())
+}
+
+func Test_time_fixed_zone_named_after_location(t *testing.T) {
+	should := require.New(t)
+	// in summer, CET location is +0200, UTC is never +0100
+	summer := time.Unix(1500000000, 0)
+	for _, zone := range []*time.Location{time.FixedZone("CET", 3600), time.FixedZone("UTC", 3600)} {
+		val := summer.In(zone)
+		encoded, err := gocodec.Marshal(val)
+		should.Nil(err)
+		decoded, err := gocodec.ReadonlyConfig.Unmarshal(encoded, (*time.Time)(nil))
+		should.Nil(err)
+		should.Equal(val.String(), decoded.(*time.Time).String())
+	}
+}
@@ -0,0 +1,121 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"github.com/esdb/gocodec"
+	"github.com/stretchr/testify/require"
+)
+
+type unmarshalIntoInner struct {
+	Field1 int8
+	Field2 string
+	Field3 []int64
+}
+
+type unmarshalIntoObject struct {
+	Field1 int8
+	Field2 []unmarshalIntoInner
+	Field3 *unmarshalIntoInner
+	Field4 [][]string
+	Field5 []int
+	Field6 []int
+	Field7 [2]*string
+	Field8 time.Time
+	Field9 int `gocodec:"-"`
+}
+
+func Test_unmarshal_into(t *testing.T) {
+	should := require.New(t)
+	hello := "hello"
+	obj := unmarshalIntoObject{
+		Field1: 1,
+		Field2: []unmarshalIntoInner{{1, "a", []int64{1, 2}}, {2, "", nil}},
+		Field3: &unmarshalIntoInner{3, "b", []int64{}},
+		Field4: [][]string{nil, {}, {"c"}},
+		Field5: []int{},
+		Field7: [2]*string{&hello, nil},
+		Field8: time.Unix(1, 2).UTC(),
+		Field9: 9,
+	}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	var dst unmarshalIntoObject
+	should.Nil(gocodec.UnmarshalInto(encoded, &dst))
+	should.Equal(0, dst.Field9)
+	dst.Field9 = 9
+	should.True(reflect.DeepEqual(obj, dst))
+	// the copy does not change with the buffer, even after the frame is decoded in place
+	_, err = gocodec.Unmarshal(encoded, (*unmarshalIntoObject)(nil))
+	should.Nil(err)
+	var decodedDst unmarshalIntoObject
+	should.Nil(gocodec.UnmarshalInto(encoded, &decodedDst))
+	decodedDst.Field9 = 9
+	should.True(reflect.DeepEqual(obj, decodedDst))
+	for i := range encoded {
+		encoded[i] = 0
+	}
+	should.True(reflect.DeepEqual(obj, dst))
+	should.Equal("hello", *dst.Field7[0])
+}
+
+func Test_unmarshal_into_reuses_capacity(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 []uint64
+		Field2 *[]string
+		Field3 []int
+	}
+	strs := []string{"a", "b"}
+	encoded, err := gocodec.Marshal(TestObject{[]uint64{1, 2}, &strs, []int{1}})
+	should.Nil(err)
+	dst := TestObject{Field1: make([]uint64, 10), Field2: &[]string{}, Field3: make([]int, 0, 1)}
+	field1 := &dst.Field1[:1][0]
+	field2 := dst.Field2
+	field3 := &dst.Field3[:1][0]
+	should.Nil(gocodec.UnmarshalInto(encoded, &dst))
+	should.Equal([]uint64{1, 2}, dst.Field1)
+	should.True(field1 == &dst.Field1[0])
+	should.Equal(strs, *dst.Field2)
+	should.True(field2 == dst.Field2)
+	should.Equal([]int{1}, dst.Field3)
+	should.True(field3 == &dst.Field3[0])
+	allocs := testing.AllocsPerRun(100, func() {
+		gocodec.UnmarshalInto(encoded, &dst)
+	})
+	should.True(allocs < 5)
+}
+
+func Test_unmarshal_into_does_not_reuse_buf(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 string
+		Field2 []int64
+		Field3 *unmarshalIntoInner
+		Field4 []string
+	}
+	obj := TestObject{"hello", []int64{1, 2}, &unmarshalIntoInner{1, "a", []int64{3}}, []string{"b", "c"}}
+	encoded, err := gocodec.Marshal(obj)
+	should.Nil(err)
+	decoded, err := gocodec.Unmarshal(encoded, (*TestObject)(nil))
+	should.Nil(err)
+	// everything but the struct itself is still inside the buffer
+	dst := *decoded.(*TestObject)
+	should.Nil(gocodec.UnmarshalInto(encoded, &dst))
+	for i := range encoded {
+		encoded[i] = 0
+	}
+	should.True(reflect.DeepEqual(obj, dst))
+}
+
+func Test_unmarshal_into_error(t *testing.T) {
+	should := require.New(t)
+	encoded, err := gocodec.Marshal([]string{"hello"})
+	should.Nil(err)
+	var dst []int
+	should.NotNil(gocodec.UnmarshalInto(encoded, &dst))
+	should.NotNil(gocodec.UnmarshalInto(encoded, dst))
+	should.NotNil(gocodec.UnmarshalInto(encoded, (*[]string)(nil)))
+	should.NotNil(gocodec.UnmarshalInto(encoded[:4], &dst))
+}
@@ -0,0 +1,116 @@
+package gocodec
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// UnknownHandler is called by Mux for the frame without handler registered for its signature
+type UnknownHandler func(signature uint32, frame []byte) error
+
+// SkipUnknown ignores the frame
+func SkipUnknown(signature uint32, frame []byte) error {
+	return nil
+}
+
+// FailOnUnknown stops the dispatching, it is the default
+func FailOnUnknown(signature uint32, frame []byte) error {
+	return fmt.Errorf("no handler for signature 0x%08x", signature)
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Mux decodes each frame as the type its handler takes, then calls the handler.
+// Handlers must be registered before dispatching.
+type Mux struct {
+	registry *Registry
+	handlers map[uint32]reflect.Value
+	unknown  UnknownHandler
+}
+
+func NewMux() *Mux {
+	return DefaultConfig.NewMux()
+}
+
+func (cfg *frozenConfig) NewMux() *Mux {
+	return &Mux{
+		registry: cfg.NewRegistry(),
+		handlers: map[uint32]reflect.Value{},
+		unknown:  FailOnUnknown,
+	}
+}
+
+// Handle registers handler of form func(*T) error for the frames of type T
+func (mux *Mux) Handle(handler interface{}) error {
+	handlerVal := reflect.ValueOf(handler)
+	handlerType := handlerVal.Type()
+	if handlerType.Kind() != reflect.Func || handlerType.NumIn() != 1 || handlerType.NumOut() != 1 ||
+		handlerType.In(0).Kind() != reflect.Ptr || handlerType.Out(0) != errorType {
+		return fmt.Errorf("handler must be func(*T) error, but found %s", handlerType.String())
+	}
+	candidatePointer := reflect.Zero(handlerType.In(0)).Interface()
+	if err := mux.registry.Register(candidatePointer); err != nil {
+		return err
+	}
+	decoder, err := decoderOfType(mux.registry.cfg, handlerType.In(0).Elem())
+	if err != nil {
+		return err
+	}
+	signature := decoder.Signature()
+	if _, found := mux.handlers[signature]; found {
+		return fmt.Errorf("handler of %s already registered", handlerType.In(0).Elem().String())
+	}
+	mux.handlers[signature] = handlerVal
+	return nil
+}
+
+// HandleUnknown changes what to do with the frame without handler, such as SkipUnknown
+func (mux *Mux) HandleUnknown(handler UnknownHandler) {
+	mux.unknown = handler
+}
+
+// Dispatch decodes next frame and calls its handler, io.EOF is returned if there is no byte left.
+// The frame is checked before its handler is looked up, so the bytes left that are not a whole frame,
+// such as a truncated tail, are reported as error.
+// The error returned by handler stops the dispatching, and is returned as it is.
+func (mux *Mux) Dispatch(iter *Iterator) error {
+	if iter.Error != nil {
+		return iter.Error
+	}
+	if len(iter.buf) == 0 {
+		return io.EOF
+	}
+	if err := checkFrame(iter.buf); err != nil {
+		iter.ReportError("Dispatch", err)
+		return iter.Error
+	}
+	entry, found := mux.registry.lookupFrame(iter.buf)
+	if !found {
+		return mux.unknown(iter.NextSignature(), iter.Skip())
+	}
+	handler := mux.handlers[entry.decoder.Signature()]
+	val := iter.UnmarshalRegistered(mux.registry)
+	if iter.Error != nil {
+		return iter.Error
+	}
+	err := handler.Call([]reflect.Value{reflect.ValueOf(val)})[0].Interface()
+	if err != nil {
+		return err.(error)
+	}
+	return nil
+}
+
+// DispatchAll dispatches every frame in buf, until the first error
+func (mux *Mux) DispatchAll(buf []byte) error {
+	iter := mux.registry.cfg.NewIterator(buf)
+	for {
+		err := mux.Dispatch(iter)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
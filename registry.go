@@ -0,0 +1,90 @@
+package gocodec
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Registry finds the type of a frame by its signature, instead of trying the candidates one by one.
+// Types are registered once, Unmarshal can be called concurrently with each other and with Register.
+type Registry struct {
+	cfg     *frozenConfig
+	mutex   sync.RWMutex
+	entries map[uint32]registryEntry
+}
+
+type registryEntry struct {
+	decoder          RootDecoder
+	candidatePointer interface{}
+}
+
+func NewRegistry() *Registry {
+	return DefaultConfig.NewRegistry()
+}
+
+func (cfg *frozenConfig) NewRegistry() *Registry {
+	return &Registry{cfg: cfg, entries: map[uint32]registryEntry{}}
+}
+
+// Register adds the types pointed by candidatePointers, such as (*Order)(nil).
+// Registering a type again is a no-op, but two types having the same signature is an error,
+// as a frame of one can be decoded as the other.
+func (registry *Registry) Register(candidatePointers ...interface{}) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	for _, candidatePointer := range candidatePointers {
+		valType := reflect.TypeOf(candidatePointer).Elem()
+		decoder, err := decoderOfType(registry.cfg, valType)
+		if err != nil {
+			return err
+		}
+		existing, found := registry.entries[decoder.Signature()]
+		if found {
+			if existing.decoder.Type() == valType {
+				continue
+			}
+			return fmt.Errorf("signature 0x%08x of %s collides with %s",
+				decoder.Signature(), valType.String(), existing.decoder.Type().String())
+		}
+		registry.entries[decoder.Signature()] = registryEntry{decoder, candidatePointer}
+	}
+	return nil
+}
+
+func (registry *Registry) lookup(signature uint32) (registryEntry, bool) {
+	registry.mutex.RLock()
+	entry, found := registry.entries[signature]
+	registry.mutex.RUnlock()
+	return entry, found
+}
+
+// lookupFrame finds the entry of the frame, the signature of plain frame decoded in place is scrambled by its state
+func (registry *Registry) lookupFrame(frame []byte) (registryEntry, bool) {
+	word := frameSignature(frame)
+	if frameHasExtendedHeader(frame) {
+		return registry.lookup(word)
+	}
+	for _, key := range frameStateKeys {
+		if entry, found := registry.lookup(word ^ key); found {
+			return entry, true
+		}
+	}
+	return registryEntry{}, false
+}
+
+// Type returns the registered type of the signature, nil if not registered
+func (registry *Registry) Type(signature uint32) reflect.Type {
+	entry, found := registry.lookup(signature)
+	if !found {
+		return nil
+	}
+	return entry.decoder.Type()
+}
+
+// Unmarshal decodes the first frame of buf, the result is a pointer to the registered type
+func (registry *Registry) Unmarshal(buf []byte) (interface{}, error) {
+	iter := registry.cfg.NewIterator(buf)
+	val := iter.UnmarshalRegistered(registry)
+	return val, iter.Error
+}
@@ -0,0 +1,63 @@
+package gocodec
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SharedView decodes each frame of the buffer at most once, when it is first asked for.
+// The decoded values are shared by all goroutines, they must be treated as readonly.
+type SharedView struct {
+	cfg               *frozenConfig
+	candidatePointers []interface{}
+	frames            []sharedFrame
+}
+
+type sharedFrame struct {
+	once sync.Once
+	buf  []byte
+	val  interface{}
+	err  error
+}
+
+func NewSharedView(buf []byte, candidatePointers ...interface{}) (*SharedView, error) {
+	return DefaultConfig.NewSharedView(buf, candidatePointers...)
+}
+
+// NewSharedView indexes the frames of buf, nothing is decoded until Get
+func (cfg *frozenConfig) NewSharedView(buf []byte, candidatePointers ...interface{}) (*SharedView, error) {
+	view := &SharedView{cfg: cfg, candidatePointers: candidatePointers}
+	iter := cfg.NewIterator(buf)
+	count := 0
+	for iter.NextSize() != 0 {
+		if err := checkFrame(iter.buf); err != nil {
+			return nil, fmt.Errorf("frame %d: %s", count, err)
+		}
+		iter.Skip()
+		count++
+	}
+	view.frames = make([]sharedFrame, count)
+	iter.Reset(buf)
+	for i := range view.frames {
+		view.frames[i].buf = iter.Skip()
+	}
+	return view, nil
+}
+
+func (view *SharedView) Len() int {
+	return len(view.frames)
+}
+
+// Get returns the decoded value of i-th frame, it is safe to be called concurrently
+func (view *SharedView) Get(i int) (interface{}, error) {
+	if i < 0 || i >= len(view.frames) {
+		return nil, fmt.Errorf("frame index %d out of range [0, %d)", i, len(view.frames))
+	}
+	frame := &view.frames[i]
+	frame.once.Do(func() {
+		iter := view.cfg.NewIterator(frame.buf)
+		frame.val = iter.UnmarshalCandidates(view.candidatePointers...)
+		frame.err = iter.Error
+	})
+	return frame.val, frame.err
+}
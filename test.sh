@@ -9,4 +9,6 @@ for d in $(go list ./... | grep -v vendor); do
         cat profile.out >> coverage.txt
         rm profile.out
     fi
-done
\ No newline at end of file
+done
+
+go test -race ./...
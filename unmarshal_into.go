@@ -0,0 +1,142 @@
+package gocodec
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+func UnmarshalInto(buf []byte, ptr interface{}) error {
+	return DefaultConfig.UnmarshalInto(buf, ptr)
+}
+
+// UnmarshalInto copies the value in the first frame of buf into *ptr, strings, slices and pointees are copied
+// onto the heap, so *ptr does not refer to buf afterwards. The frame is read without being decoded in place,
+// it can be either portable or already decoded.
+// The slices and pointees already in *ptr are reused when they are large enough, like encoding/json does,
+// unless they are inside buf, and the fields tagged with gocodec:"-" are left as they are.
+func (cfg *frozenConfig) UnmarshalInto(buf []byte, ptr interface{}) error {
+	ptrType := reflect.TypeOf(ptr)
+	if ptrType == nil || ptrType.Kind() != reflect.Ptr {
+		return errors.New("UnmarshalInto: ptr must be a pointer")
+	}
+	dst := ptrOfEmptyInterface(ptr)
+	if dst == nil {
+		return errors.New("UnmarshalInto: ptr must not be nil")
+	}
+	root, err := cfg.rootFrameValue(buf, ptrType.Elem())
+	if err != nil {
+		return err
+	}
+	bufStart := uintptr(unsafe.Pointer(&buf[0]))
+	copier := frameCopier{bufStart: bufStart, bufEnd: bufStart + uintptr(len(buf))}
+	return copier.copy(root, dst)
+}
+
+// frameCopier copies the values inside a frame onto the heap
+type frameCopier struct {
+	// the memory of buf, what *ptr has inside it is not reused, as it is gone once buf is reused
+	bufStart uintptr
+	bufEnd   uintptr
+}
+
+func (copier *frameCopier) reusable(ptr uintptr) bool {
+	return ptr < copier.bufStart || ptr >= copier.bufEnd
+}
+
+// copy writes the value into the memory at dst
+func (copier *frameCopier) copy(value frameValue, dst unsafe.Pointer) error {
+	switch value.kind() {
+	case frameKindScalar:
+		copy(ptrAsBytes(int(value.valType.Size()), dst), value.bytes())
+	case frameKindString:
+		str, _, err := value.string()
+		if err != nil {
+			return err
+		}
+		*(*string)(dst) = str
+	case frameKindStruct:
+		if encoderIsRaw(value.encoder) {
+			copy(ptrAsBytes(int(value.valType.Size()), dst), value.bytes())
+			return nil
+		}
+		for _, field := range value.fields() {
+			if err := copier.copy(value.field(field), unsafe.Pointer(uintptr(dst)+field.offset)); err != nil {
+				return withPathElement("."+field.name, err)
+			}
+		}
+	case frameKindArray:
+		return copier.elements(value.array(), dst)
+	case frameKindSlice:
+		elements, isNil, err := value.slice()
+		if err != nil {
+			return err
+		}
+		val := reflect.NewAt(value.valType, dst).Elem()
+		switch {
+		case isNil:
+			val.Set(reflect.Zero(value.valType))
+			return nil
+		case val.IsNil() || !copier.reusable(val.Pointer()):
+			val.Set(reflect.MakeSlice(value.valType, elements.length, elements.length))
+		case val.Cap() >= elements.length:
+			val.SetLen(elements.length)
+		default:
+			val.Set(reflect.MakeSlice(value.valType, elements.length, elements.length))
+		}
+		if elements.length == 0 {
+			return nil
+		}
+		return copier.elements(elements, unsafe.Pointer(val.Pointer()))
+	case frameKindPointer:
+		elem, isNil, err := value.deref()
+		if err != nil {
+			return err
+		}
+		val := reflect.NewAt(value.valType, dst).Elem()
+		if isNil {
+			val.Set(reflect.Zero(value.valType))
+			return nil
+		}
+		if val.IsNil() || !copier.reusable(val.Pointer()) {
+			val.Set(reflect.New(elem.valType))
+		}
+		return copier.copy(elem, unsafe.Pointer(val.Pointer()))
+	case frameKindTime:
+		val, err := value.time()
+		if err != nil {
+			return err
+		}
+		*(*time.Time)(dst) = val.toTime()
+	case frameKindBlob:
+		data, _, err := value.blob()
+		if err != nil {
+			return err
+		}
+		obj := reflect.New(value.valType)
+		if err := obj.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("UnmarshalBinary: %s: %s", value.valType.String(), err)
+		}
+		reflect.NewAt(value.valType, dst).Elem().Set(obj.Elem())
+	default:
+		return fmt.Errorf("%s has custom codec, the frame can not be inspected", value.valType.String())
+	}
+	return nil
+}
+
+func (copier *frameCopier) elements(elements frameElements, dst unsafe.Pointer) error {
+	if elements.raw() {
+		copy(ptrAsBytes(int(elements.size()), dst), elements.bytes())
+		return nil
+	}
+	for i := 0; i < elements.length; i++ {
+		elemPtr := unsafe.Pointer(uintptr(dst) + uintptr(i)*elements.elemType.Size())
+		if err := copier.copy(elements.at(i), elemPtr); err != nil {
+			return withPathElement(fmt.Sprintf("[%d]", i), err)
+		}
+	}
+	return nil
+}
@@ -10,6 +10,11 @@ type emptyInterface struct {
 	word unsafe.Pointer
 }
 
+type stringReadonlyHeader struct {
+	Data unsafe.Pointer
+	Len  int
+}
+
 type stringWritableHeader struct {
 	Data uintptr
 	Len  int